@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// action is a call to an xbps-src helper function that xdeps does not carry out, such as vinstall
+// or msg_warn.
+type action struct {
+	Func    string   `json:"func"`
+	Args    []string `json:"args"`
+	PkgName string   `json:"pkgname"`
+}
+
+// actionLog records the helper calls made while evaluating a template. A nil *actionLog discards
+// everything recorded.
+type actionLog struct {
+	mu      sync.Mutex
+	actions []action
+}
+
+func (l *actionLog) record(ctx context.Context, args []string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.actions = append(l.actions, action{
+		Func:    args[0],
+		Args:    append([]string{}, args[1:]...),
+		PkgName: getPackage(ctx),
+	})
+}
+
+// list returns all actions recorded so far.
+func (l *actionLog) list() []action {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]action(nil), l.actions...)
+}
+
+type actionsKey struct{}
+
+// withActions returns a context carrying the log that helper functions record their calls in.
+func withActions(ctx context.Context, log *actionLog) context.Context {
+	return context.WithValue(ctx, actionsKey{}, log)
+}
+
+// actionsFrom returns the action log carried by ctx, or nil if it carries none.
+func actionsFrom(ctx context.Context) *actionLog {
+	log, _ := ctx.Value(actionsKey{}).(*actionLog)
+	return log
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mvdan.cc/sh/interp"
+)
+
+// AllowExec controls whether commands in permittedExecs without a builtin implementation (or
+// called with options the builtin does not support) may be run on the host.
+var AllowExec = false
+
+// errUnsupported is returned by builtins when called in a way they do not implement. If AllowExec
+// is set, the real command is run instead.
+type errUnsupported string
+
+func (e errUnsupported) Error() string {
+	return "unsupported usage: " + string(e)
+}
+
+// Builtin implementations of permitted execs. These cover the subsets of each command that
+// templates use, operating only on stdin, so that evaluating a template does not depend on the
+// host.
+var builtinExecs = map[string]interp.ModuleExec{
+	"sed":       builtinSed,
+	"grep":      builtinGrep,
+	"egrep":     builtinGrep,
+	"fgrep":     builtinGrep,
+	"date":      builtinDate,
+	"sha256sum": builtinChecksum,
+	"sha1sum":   builtinChecksum,
+	"md5sum":    builtinChecksum,
+	"shasum":    builtinChecksum,
+}
+
+// readLines reads all lines of r, without their line endings.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// compileRegexp compiles an extended regular expression pattern, which may use the (?i) and (?:)
+// syntax of regexp. Unlike regexp.Compile, matches are leftmost-longest as in POSIX. GNU word
+// boundaries (\< and \>) are unsupported. Patterns regexp cannot compile, such as those using
+// backreferences, are also reported as unsupported, so that the host tool can handle them.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if err := checkEscapes(pattern); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errUnsupported(err.Error())
+	}
+	re.Longest()
+	return re, nil
+}
+
+// checkEscapes returns an errUnsupported error if pattern uses a GNU word boundary outside of a
+// bracket expression.
+func checkEscapes(pattern string) error {
+	inBracket := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case inBracket:
+			inBracket = c != ']'
+		case c == '[':
+			inBracket = true
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				i++
+			}
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			if d := pattern[i]; d == '<' || d == '>' {
+				return errUnsupported("regular expression \\" + string(d))
+			}
+		}
+	}
+	return nil
+}
+
+// stdinOnly returns an errUnsupported error if files names anything other than stdin.
+func stdinOnly(cmd string, files []string) error {
+	for _, f := range files {
+		if f != "-" {
+			return errUnsupported(cmd + " on files")
+		}
+	}
+	return nil
+}
+
+// sed
+
+// sedSubst is a sed s/// command.
+type sedSubst struct {
+	re     *regexp.Regexp
+	repl   string
+	global bool
+	print  bool
+}
+
+// builtinSed implements sed [-E|-r] [-n] [-e] script... for scripts consisting of s/// commands
+// separated by semicolons or newlines.
+func builtinSed(ctx context.Context, path string, args []string) error {
+	cmd, args := args[0], args[1:]
+	var (
+		ere, quiet bool
+		scripts    []string
+		files      []string
+	)
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-E", "-r":
+			ere = true
+		case "-n":
+			quiet = true
+		case "-e":
+			if i+1 == len(args) {
+				return fmt.Errorf("%s: -e requires an argument", cmd)
+			}
+			i++
+			scripts = append(scripts, args[i])
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return errUnsupported(cmd + " " + arg)
+			}
+			if len(scripts) == 0 {
+				scripts = append(scripts, arg)
+			} else {
+				files = append(files, arg)
+			}
+		}
+	}
+	if len(scripts) == 0 {
+		return fmt.Errorf("%s: no script given", cmd)
+	}
+	if err := stdinOnly(cmd, files); err != nil {
+		return err
+	}
+
+	var substs []*sedSubst
+	for _, script := range scripts {
+		s, err := parseSedScript(script, ere)
+		if _, ok := err.(errUnsupported); ok {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", cmd, err)
+		}
+		substs = append(substs, s...)
+	}
+
+	mod, ok := interp.FromModuleContext(ctx)
+	if !ok {
+		return fmt.Errorf("unable to acquire module context")
+	}
+	lines, err := readLines(mod.Stdin)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	for _, line := range lines {
+		for _, s := range substs {
+			var matched bool
+			line, matched = s.apply(line)
+			if matched && s.print {
+				out.WriteString(line + "\n")
+			}
+		}
+		if !quiet {
+			out.WriteString(line + "\n")
+		}
+	}
+	_, err = io.WriteString(mod.Stdout, out.String())
+	return err
+}
+
+// parseSedScript parses a sed script made up only of s/// commands. If ere is false, patterns are
+// POSIX basic regular expressions.
+func parseSedScript(script string, ere bool) ([]*sedSubst, error) {
+	var substs []*sedSubst
+	for {
+		script = strings.TrimLeft(script, " \t\n;")
+		if script == "" {
+			return substs, nil
+		}
+		if script[0] != 's' || len(script) < 2 {
+			return nil, errUnsupported("sed command " + strconv.Quote(script))
+		}
+
+		delim := script[1]
+		rest := script[2:]
+		var fields [2]string
+		for i := range fields {
+			field, n, ok := sedField(rest, delim)
+			if !ok {
+				return nil, fmt.Errorf("unterminated s command: %q", script)
+			}
+			fields[i], rest = field, rest[n:]
+		}
+
+		s := &sedSubst{repl: fields[1]}
+		flags := ""
+		for rest != "" && rest[0] != ';' && rest[0] != '\n' {
+			flags, rest = flags+rest[:1], rest[1:]
+		}
+		script = rest
+
+		pattern := fields[0]
+		if !ere {
+			pattern = breToERE(pattern)
+		}
+		for _, f := range strings.TrimSpace(flags) {
+			switch f {
+			case 'g':
+				s.global = true
+			case 'p':
+				s.print = true
+			case 'I', 'i':
+				pattern = "(?i)" + pattern
+			default:
+				return nil, errUnsupported("sed s flag " + strconv.QuoteRune(f))
+			}
+		}
+
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		s.re = re
+		substs = append(substs, s)
+	}
+}
+
+// sedField returns the text of s up to an unescaped delim, with escaped delimiters unescaped, and
+// the number of bytes consumed including the delimiter.
+func sedField(s string, delim byte) (field string, n int, ok bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == delim:
+			return b.String(), i + 1, true
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] != delim {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+// apply applies the substitution to a line, returning the result and whether anything matched.
+func (s *sedSubst) apply(line string) (string, bool) {
+	n := 1
+	if s.global {
+		n = -1
+	}
+	matches := s.re.FindAllStringSubmatchIndex(line, n)
+	if len(matches) == 0 {
+		return line, false
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(line[last:m[0]])
+		s.expand(&b, line, m)
+		last = m[1]
+	}
+	b.WriteString(line[last:])
+	return b.String(), true
+}
+
+// expand writes the replacement for a single match to b, replacing & with the match and \1-\9 with
+// its groups.
+func (s *sedSubst) expand(b *strings.Builder, line string, m []int) {
+	group := func(i int) {
+		if 2*i+1 < len(m) && m[2*i] >= 0 {
+			b.WriteString(line[m[2*i]:m[2*i+1]])
+		}
+	}
+	repl := s.repl
+	for i := 0; i < len(repl); i++ {
+		switch c := repl[i]; {
+		case c == '&':
+			group(0)
+		case c == '\\' && i+1 < len(repl):
+			i++
+			switch d := repl[i]; {
+			case d >= '0' && d <= '9':
+				group(int(d - '0'))
+			case d == 'n':
+				b.WriteByte('\n')
+			case d == 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(d)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// breToERE converts a POSIX basic regular expression to the extended syntax used by regexp: \(,
+// \), \{, \}, \+, \?, and \| become operators, and their unescaped forms become literals.
+func breToERE(bre string) string {
+	var b strings.Builder
+	inBracket := false
+	for i := 0; i < len(bre); i++ {
+		c := bre[i]
+		switch {
+		case inBracket:
+			if c == ']' {
+				inBracket = false
+			}
+			b.WriteByte(c)
+		case c == '[':
+			inBracket = true
+			b.WriteByte(c)
+			// A ] immediately after [ or [^ is literal.
+			if i+1 < len(bre) && bre[i+1] == '^' {
+				i++
+				b.WriteByte('^')
+			}
+			if i+1 < len(bre) && bre[i+1] == ']' {
+				i++
+				b.WriteByte(']')
+			}
+		case c == '\\' && i+1 < len(bre):
+			i++
+			switch d := bre[i]; d {
+			case '(', ')', '{', '}', '+', '?', '|':
+				b.WriteByte(d)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(d)
+			}
+		case strings.IndexByte("(){}+?|", c) != -1:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '*' && (i == 0 || bre[i-1] == '^' && i == 1):
+			// A leading * is literal.
+			b.WriteString(`\*`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// grep
+
+// builtinGrep implements grep, egrep, and fgrep with the options -E, -F, -q, -v, -i, -x, -c, and
+// -e, reading from stdin. As with grep, it fails with exit status 1 if no lines are selected.
+func builtinGrep(ctx context.Context, path string, args []string) error {
+	cmd, args := args[0], args[1:]
+	var (
+		ere      = cmd == "egrep"
+		fixed    = cmd == "fgrep"
+		quiet    bool
+		invert   bool
+		icase    bool
+		line     bool
+		count    bool
+		patterns []string
+		files    []string
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-e" {
+			if i+1 == len(args) {
+				return fmt.Errorf("%s: -e requires an argument", cmd)
+			}
+			i++
+			patterns = append(patterns, args[i])
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if len(patterns) == 0 {
+				patterns = append(patterns, arg)
+			} else {
+				files = append(files, arg)
+			}
+			continue
+		}
+		for _, f := range arg[1:] {
+			switch f {
+			case 'E':
+				ere = true
+			case 'F':
+				fixed = true
+			case 'q':
+				quiet = true
+			case 's':
+				// Errors about unreadable files are never reported.
+			case 'v':
+				invert = true
+			case 'i':
+				icase = true
+			case 'x':
+				line = true
+			case 'c':
+				count = true
+			default:
+				return errUnsupported(cmd + " -" + string(f))
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return fmt.Errorf("%s: no pattern given", cmd)
+	}
+	if err := stdinOnly(cmd, files); err != nil {
+		return err
+	}
+
+	exprs := make([]string, len(patterns))
+	for i, p := range patterns {
+		switch {
+		case fixed:
+			p = regexp.QuoteMeta(p)
+		case !ere:
+			p = breToERE(p)
+		}
+		if line {
+			p = "^(?:" + p + ")$"
+		}
+		exprs[i] = "(?:" + p + ")"
+	}
+	expr := strings.Join(exprs, "|")
+	if icase {
+		expr = "(?i)" + expr
+	}
+	re, err := compileRegexp(expr)
+	if _, ok := err.(errUnsupported); ok {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%s: %v", cmd, err)
+	}
+
+	mod, ok := interp.FromModuleContext(ctx)
+	if !ok {
+		return fmt.Errorf("unable to acquire module context")
+	}
+	lines, err := readLines(mod.Stdin)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	selected := 0
+	for _, l := range lines {
+		if re.MatchString(l) == invert {
+			continue
+		}
+		selected++
+		if !quiet && !count {
+			out.WriteString(l + "\n")
+		}
+	}
+	if count && !quiet {
+		fmt.Fprintf(&out, "%d\n", selected)
+	}
+	if _, err := io.WriteString(mod.Stdout, out.String()); err != nil {
+		return err
+	}
+	if selected == 0 {
+		return interp.ExitStatus(1)
+	}
+	return nil
+}
+
+// date
+
+// timeNow returns the current time used by date when SOURCE_DATE_EPOCH is not set.
+var timeNow = time.Now
+
+// builtinDate implements date [-u] [-d @seconds] [+format]. So that results are reproducible, the
+// current time is taken from SOURCE_DATE_EPOCH if it is set, and times are always in UTC.
+func builtinDate(ctx context.Context, path string, args []string) error {
+	cmd, args := args[0], args[1:]
+	mod, ok := interp.FromModuleContext(ctx)
+	if !ok {
+		return fmt.Errorf("unable to acquire module context")
+	}
+
+	epoch := mod.Env.Get("SOURCE_DATE_EPOCH").String()
+	format := "%a %b %e %H:%M:%S %Z %Y"
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-u":
+		case arg == "-d" && i+1 < len(args) && strings.HasPrefix(args[i+1], "@"):
+			i++
+			epoch = args[i][1:]
+		case strings.HasPrefix(arg, "+"):
+			format = arg[1:]
+		default:
+			return errUnsupported(cmd + " " + arg)
+		}
+	}
+
+	now := timeNow()
+	if epoch != "" {
+		secs, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid time: %q", cmd, epoch)
+		}
+		now = time.Unix(secs, 0)
+	}
+	out, err := strftime(format, now.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %v", cmd, err)
+	}
+	return write(ctx, out+"\n")
+}
+
+// strftime formats t using the common subset of strftime conversions.
+func strftime(format string, t time.Time) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		if i++; i == len(format) {
+			return "", fmt.Errorf("incomplete conversion in format: %q", format)
+		}
+		switch format[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		default:
+			return "", errUnsupported("date conversion %" + string(format[i]))
+		}
+	}
+	return b.String(), nil
+}
+
+// Checksums
+
+var checksumHashes = map[string]func() hash.Hash{
+	"md5sum":    md5.New,
+	"sha1sum":   sha1.New,
+	"sha256sum": sha256.New,
+}
+
+var shasumAlgorithms = map[string]func() hash.Hash{
+	"1":   sha1.New,
+	"224": sha256.New224,
+	"256": sha256.New,
+	"384": sha512.New384,
+	"512": sha512.New,
+}
+
+// builtinChecksum implements md5sum, sha1sum, sha256sum, and shasum [-a alg] over stdin.
+func builtinChecksum(ctx context.Context, path string, args []string) error {
+	cmd, args := args[0], args[1:]
+	newHash := checksumHashes[cmd]
+	var files []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case cmd == "shasum" && arg == "-a" && i+1 < len(args):
+			i++
+			if newHash = shasumAlgorithms[args[i]]; newHash == nil {
+				return fmt.Errorf("%s: unknown algorithm: %q", cmd, args[i])
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return errUnsupported(cmd + " " + arg)
+		default:
+			files = append(files, arg)
+		}
+	}
+	if newHash == nil {
+		newHash = sha1.New
+	}
+	if err := stdinOnly(cmd, files); err != nil {
+		return err
+	}
+
+	mod, ok := interp.FromModuleContext(ctx)
+	if !ok {
+		return fmt.Errorf("unable to acquire module context")
+	}
+	h := newHash()
+	if _, err := io.Copy(h, mod.Stdin); err != nil {
+		return err
+	}
+	return write(ctx, hex.EncodeToString(h.Sum(nil))+"  -\n")
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mvdan.cc/sh/expand"
+	"mvdan.cc/sh/interp"
+	"mvdan.cc/sh/syntax"
+)
+
+// runBuiltins runs a shell script with only builtinExecs available, returning its stdout and the
+// error it exited with.
+func runBuiltins(t *testing.T, script, stdin string, env ...string) (string, error) {
+	t.Helper()
+	file, err := syntax.NewParser().Parse(strings.NewReader(script), "test")
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", script, err)
+	}
+	var out strings.Builder
+	runner, err := interp.New(
+		interp.Env(expand.ListEnviron(env...)),
+		interp.StdIO(strings.NewReader(stdin), &out, &out),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	runner.Exec = func(ctx context.Context, path string, args []string) error {
+		if fn := builtinExecs[args[0]]; fn != nil {
+			return fn(ctx, path, args)
+		}
+		return fmt.Errorf("no builtin: %s", args[0])
+	}
+	err = runner.Run(context.Background(), file)
+	return out.String(), err
+}
+
+func TestBreToERE(t *testing.T) {
+	cases := []struct {
+		In, Want string
+	}{
+		{`abc`, `abc`},
+		{`\(a\)\{2\}`, `(a){2}`},
+		{`(a){2}`, `\(a\)\{2\}`},
+		{`a\+b\?c\|d`, `a+b?c|d`},
+		{`a+b?c|d`, `a\+b\?c\|d`},
+		{`*a`, `\*a`},
+		{`^*a`, `^\*a`},
+		{`a*`, `a*`},
+		{`[(]\.`, `[(]\.`},
+		{`[]a(]`, `[]a(]`},
+		{`[^]a]+`, `[^]a]\+`},
+		{`\<word\>`, `\<word\>`},
+	}
+	for _, c := range cases {
+		if got := breToERE(c.In); got != c.Want {
+			t.Errorf("breToERE(%q) = %q; want %q", c.In, got, c.Want)
+		}
+	}
+}
+
+func TestCompileRegexp(t *testing.T) {
+	cases := []struct {
+		Pattern, In, Want string
+		Unsupported       bool
+	}{
+		// Matches are leftmost-longest, not leftmost-first.
+		{`a|ab`, "abc", "ab", false},
+		{`(a|ab)(c|bcd)`, "abcd", "abcd", false},
+		{`[<>]+`, "a<>b", "<>", false},
+		{`\<a`, "", "", true},
+		{`a\>`, "", "", true},
+		{`(a)\1`, "", "", true},
+	}
+	for _, c := range cases {
+		re, err := compileRegexp(c.Pattern)
+		if _, ok := err.(errUnsupported); ok != c.Unsupported {
+			t.Errorf("compileRegexp(%q) error = %v; want unsupported %t", c.Pattern, err, c.Unsupported)
+			continue
+		} else if err != nil {
+			continue
+		}
+		if got := re.FindString(c.In); got != c.Want {
+			t.Errorf("compileRegexp(%q).FindString(%q) = %q; want %q", c.Pattern, c.In, got, c.Want)
+		}
+	}
+}
+
+func TestUnsupportedFallback(t *testing.T) {
+	if _, err := exec.LookPath("sed"); err != nil {
+		t.Skip("sed not found")
+	}
+	defer func(old bool) { AllowExec = old }(AllowExec)
+
+	// Backreferences are unsupported by regexp, so sed must be run on the host.
+	const template = `pkgname=foo
+depends="$(echo aab | sed 's/\(a\)\1/x/')"
+`
+	AllowExec = false
+	if _, err := evalTestTemplate(t, extractDeps, template); err == nil || !strings.Contains(err.Error(), "-exec") {
+		t.Errorf("extractDeps() error = %v; want exec denied", err)
+	}
+
+	AllowExec = true
+	data, err := evalTestTemplate(t, extractDeps, template)
+	if err != nil {
+		t.Fatalf("extractDeps() with AllowExec error = %v", err)
+	}
+	if got, want := data.deps["depends"], []string{"xb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("depends = %q; want %q", got, want)
+	}
+}
+
+func TestParseSedScript(t *testing.T) {
+	cases := []struct {
+		Script      string
+		ERE         bool
+		In, Want    string
+		Unsupported bool
+	}{
+		{`s/a/b/`, false, "aaa", "baa", false},
+		{`s/a/b/g`, false, "aaa", "bbb", false},
+		{`s|/usr|/opt|`, false, "/usr/lib", "/opt/lib", false},
+		{`s/\//-/g`, false, "a/b/c", "a-b-c", false},
+		{`s/\(.*\)-\([0-9]*\)/\2 \1/`, false, "foo-12", "12 foo", false},
+		{`s/(.*)-([0-9]*)/\2 \1/`, true, "foo-12", "12 foo", false},
+		{`s/(x)/y/`, false, "(x)", "y", false},
+		{`s/A/&&/I`, false, "a", "aa", false},
+		{`s/a/b/; s/b/c/`, false, "a", "c", false},
+		{"s/a/b/\ns/c/d/g", false, "acc", "bdd", false},
+		{`s/a*/x/`, false, "aab", "xb", false},
+		{`y/a/b/`, false, "", "", true},
+		{`s/a/b/w out`, false, "", "", true},
+		{`s/\<a/b/`, false, "", "", true},
+		{`s/\(a\)\1/x/`, false, "", "", true},
+	}
+	for _, c := range cases {
+		substs, err := parseSedScript(c.Script, c.ERE)
+		if _, ok := err.(errUnsupported); ok != c.Unsupported {
+			t.Errorf("parseSedScript(%q) error = %v; want unsupported %t", c.Script, err, c.Unsupported)
+			continue
+		} else if err != nil {
+			continue
+		}
+		got := c.In
+		for _, s := range substs {
+			got, _ = s.apply(got)
+		}
+		if got != c.Want {
+			t.Errorf("parseSedScript(%q) applied to %q = %q; want %q", c.Script, c.In, got, c.Want)
+		}
+	}
+
+	if _, err := parseSedScript(`s/a/b`, false); err == nil {
+		t.Error("parseSedScript with unterminated s command: expected error")
+	}
+}
+
+func TestBuiltinSed(t *testing.T) {
+	cases := []struct {
+		Script, Want string
+	}{
+		{`sed s/o/0/g`, "f00\nbar\n"},
+		{`sed -n s/o/0/p`, "f0o\n"},
+		{`sed -e s/f/F/ -e s/b/B/`, "Foo\nBar\n"},
+		{`sed -E 's/(o+)/<\1>/'`, "f<oo>\nbar\n"},
+	}
+	for _, c := range cases {
+		got, err := runBuiltins(t, c.Script, "foo\nbar\n")
+		if err != nil || got != c.Want {
+			t.Errorf("%s = %q, %v; want %q, nil", c.Script, got, err, c.Want)
+		}
+	}
+
+	if _, err := runBuiltins(t, `sed -i s/a/b/ file`, ""); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("sed -i error = %v; want unsupported usage", err)
+	}
+}
+
+func TestBuiltinGrep(t *testing.T) {
+	const in = "foo\nFoo\nbar\nfoo.bar\n"
+	cases := []struct {
+		Script, Want string
+		Status       interp.ExitStatus
+	}{
+		{`grep foo`, "foo\nfoo.bar\n", 0},
+		{`grep -i foo`, "foo\nFoo\nfoo.bar\n", 0},
+		{`grep -v foo`, "Foo\nbar\n", 0},
+		{`grep -x foo`, "foo\n", 0},
+		{`grep -c o`, "3\n", 0},
+		{`grep -ic FOO`, "3\n", 0},
+		{`grep -q foo`, "", 0},
+		{`grep -q baz`, "", 1},
+		{`grep baz`, "", 1},
+		{`grep -F o.b`, "foo.bar\n", 0},
+		{`fgrep o.b`, "foo.bar\n", 0},
+		{`grep 'o\{2\}\.'`, "foo.bar\n", 0},
+		{`grep -E '^(F|b)'`, "Foo\nbar\n", 0},
+		{`egrep 'a|F'`, "Foo\nbar\nfoo.bar\n", 0},
+		{`grep -e bar -e Foo`, "Foo\nbar\nfoo.bar\n", 0},
+		{`grep -sx -e bar -`, "bar\n", 0},
+	}
+	for _, c := range cases {
+		got, err := runBuiltins(t, c.Script, in)
+		var status interp.ExitStatus
+		if err != nil {
+			var ok bool
+			if status, ok = err.(interp.ExitStatus); !ok {
+				t.Errorf("%s error = %v; want exit status %d", c.Script, err, c.Status)
+				continue
+			}
+		}
+		if got != c.Want || status != c.Status {
+			t.Errorf("%s = %q, exit status %d; want %q, exit status %d", c.Script, got, status, c.Want, c.Status)
+		}
+	}
+
+	for _, script := range []string{`grep -r foo`, `grep foo file`, `grep '\<foo'`, `grep '\(o\)\1'`} {
+		if _, err := runBuiltins(t, script, in); err == nil || !strings.Contains(err.Error(), "unsupported") {
+			t.Errorf("%s error = %v; want unsupported usage", script, err)
+		}
+	}
+}
+
+func TestStrftime(t *testing.T) {
+	tm := time.Date(2020, time.March, 5, 7, 8, 9, 0, time.UTC)
+	cases := []struct {
+		Format, Want string
+		Unsupported  bool
+	}{
+		{"%Y-%m-%d %H:%M:%S", "2020-03-05 07:08:09", false},
+		{"%F %T", "2020-03-05 07:08:09", false},
+		{"%y %e %j", "20  5 065", false},
+		{"%a %A %b %h %B", "Thu Thursday Mar Mar March", false},
+		{"%s %Z %z %%", "1583392089 UTC +0000 %", false},
+		{"%a %b %e %H:%M:%S %Z %Y", "Thu Mar  5 07:08:09 UTC 2020", false},
+		{"%N", "", true},
+	}
+	for _, c := range cases {
+		got, err := strftime(c.Format, tm)
+		if _, ok := err.(errUnsupported); ok != c.Unsupported {
+			t.Errorf("strftime(%q) error = %v; want unsupported %t", c.Format, err, c.Unsupported)
+		} else if got != c.Want {
+			t.Errorf("strftime(%q) = %q; want %q", c.Format, got, c.Want)
+		}
+	}
+	if _, err := strftime("%", tm); err == nil {
+		t.Error("strftime with incomplete conversion: expected error")
+	}
+}
+
+func TestBuiltinDate(t *testing.T) {
+	got, err := runBuiltins(t, `date +%F`, "", "SOURCE_DATE_EPOCH=86400")
+	if err != nil || got != "1970-01-02\n" {
+		t.Errorf("date with SOURCE_DATE_EPOCH = %q, %v; want %q, nil", got, err, "1970-01-02\n")
+	}
+	got, err = runBuiltins(t, `date -u -d @0 +%s`, "")
+	if err != nil || got != "0\n" {
+		t.Errorf("date -d @0 = %q, %v; want %q, nil", got, err, "0\n")
+	}
+
+	// Without SOURCE_DATE_EPOCH, the current time is used.
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	timeNow = func() time.Time { return time.Date(2020, 3, 5, 7, 8, 9, 0, time.FixedZone("X", 3600)) }
+	got, err = runBuiltins(t, `date +%FT%T%z`, "")
+	if want := "2020-03-05T06:08:09+0000\n"; err != nil || got != want {
+		t.Errorf("date without SOURCE_DATE_EPOCH = %q, %v; want %q, nil", got, err, want)
+	}
+	if _, err := runBuiltins(t, `date +%F`, "", "SOURCE_DATE_EPOCH=soon"); err == nil {
+		t.Error("date with invalid SOURCE_DATE_EPOCH: expected error")
+	}
+}
+
+func TestBuiltinChecksum(t *testing.T) {
+	cases := []struct {
+		Script, Want string
+	}{
+		{`sha256sum`, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae  -\n"},
+		{`md5sum -`, "acbd18db4cc2f85cedef654fccc4a4d8  -\n"},
+		{`shasum -a 1`, "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33  -\n"},
+	}
+	for _, c := range cases {
+		got, err := runBuiltins(t, c.Script, "foo")
+		if err != nil || got != c.Want {
+			t.Errorf("%s = %q, %v; want %q, nil", c.Script, got, err, c.Want)
+		}
+	}
+}
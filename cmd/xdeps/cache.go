@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// cacheVersion is part of every cache key. It must be incremented whenever a change to xdeps
+// changes the results of evaluating a template or the format of cached results.
+const cacheVersion = 2
+
+// resultCache is an on-disk cache of template evaluation results. Results are stored under a hash
+// of everything that affects them (see key), so a changed input simply misses the cache. Each
+// template, evaluated in a given mode, profile, and configuration, has its own scope in the cache
+// holding results for each version of its files.
+type resultCache struct {
+	dir string
+
+	mu    sync.Mutex
+	used  map[string]bool
+	stats cacheStats
+}
+
+// cacheStats counts how the cache was used.
+type cacheStats struct {
+	Hits   int
+	Misses int
+	Stores int
+	Errors int
+	Pruned int
+}
+
+func (s cacheStats) String() string {
+	return fmt.Sprintf("%d hits, %d misses, %d stored, %d errors, %d pruned",
+		s.Hits, s.Misses, s.Stores, s.Errors, s.Pruned)
+}
+
+// cachedResult is the result of evaluating a template, as stored in the cache.
+type cachedResult struct {
+	Data *cachedData  `json:"data,omitempty"`
+	Err  *evalError   `json:"error,omitempty"`
+	Diag *diagnostics `json:"diagnostics,omitempty"`
+}
+
+// cachedData holds the fields of templateData that are produced by evaluating a template.
+type cachedData struct {
+	PkgName     string        `json:"pkgname"`
+	Deps        stringLists   `json:"deps"`
+	Options     []buildOption `json:"options,omitempty"`
+	Subpackages []*subpackage `json:"subpackages,omitempty"`
+	Meta        *templateMeta `json:"meta,omitempty"`
+	Matrix      *optionMatrix `json:"matrix,omitempty"`
+	Actions     []action      `json:"actions,omitempty"`
+}
+
+func newCachedResult(data *templateData, err *evalError, diag *diagnostics) *cachedResult {
+	r := &cachedResult{Err: err, Diag: diag}
+	if data != nil {
+		r.Data = &cachedData{
+			PkgName:     data.pkgname,
+			Deps:        data.deps,
+			Options:     data.options,
+			Subpackages: data.subpackages,
+			Meta:        data.meta,
+			Matrix:      data.matrix,
+			Actions:     data.actions,
+		}
+	}
+	return r
+}
+
+func (r *cachedResult) templateData() *templateData {
+	if r.Data == nil {
+		return nil
+	}
+	return &templateData{
+		pkgname:     r.Data.PkgName,
+		deps:        r.Data.Deps,
+		options:     r.Data.Options,
+		subpackages: r.Data.Subpackages,
+		meta:        r.Data.Meta,
+		matrix:      r.Data.Matrix,
+		actions:     r.Data.Actions,
+	}
+}
+
+// cacheable returns whether a result with the given error may be cached. Sandbox violations
+// depend on timing and the host, so they are always evaluated again.
+func cacheable(err *evalError) bool {
+	return err == nil || err.Kind != errorSandbox
+}
+
+// openCache opens the cache in dir, creating it if necessary.
+func openCache(dir string) (*resultCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &resultCache{dir: dir, used: map[string]bool{}}, nil
+}
+
+// key returns the cache key of evaluating the template at path in the given mode and profile. The
+// key covers the template, the files/ and patches/ directories beside it, Common, enabled build
+// options, and any flags affecting evaluation. It has the form scope/hash, where scope covers
+// everything except the contents of the template's files, so that results for older versions of
+// them share a scope and can be pruned. Templates read from stdin cannot be cached.
+func (c *resultCache) key(mode, path string, prof *profile) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "xdeps %d\x00mode %s\x00", cacheVersion, mode)
+	if prof != nil {
+		fmt.Fprintf(h, "profile %s %s\x00", prof.host.name, prof.target.name)
+	}
+
+	opts := make([]string, 0, len(OptionFlags))
+	for opt, on := range OptionFlags {
+		opts = append(opts, opt+"="+strconv.FormatBool(on))
+	}
+	sort.Strings(opts)
+	fmt.Fprintf(h, "options %s\x00", strings.Join(opts, ","))
+	fmt.Fprintf(h, "exec %t\x00matrix %d\x00diag %t\x00read %s\x00",
+		AllowExec, MatrixMax, Diagnostics, strings.Join(Sandbox.ReadPaths, ","))
+	if Common != nil {
+		fmt.Fprintf(h, "common %s\x00", Common.digest)
+	}
+
+	// Templates are identified by their package directory, so that copies of a source tree share
+	// results.
+	dir := filepath.Dir(path)
+	fmt.Fprintf(h, "template %s\x00", filepath.Join(filepath.Base(dir), filepath.Base(path)))
+	scope := hex.EncodeToString(h.Sum(nil))[:32]
+
+	if err := hashFile(h, path); err != nil {
+		return "", err
+	}
+	for _, sub := range []string{"files", "patches"} {
+		if err := hashTree(h, filepath.Join(dir, sub)); err != nil {
+			return "", err
+		}
+	}
+	return scope + "/" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the name and contents of the file at path to h.
+func hashFile(h io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(h, "file %s\x00", filepath.Base(path))
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// hashTree writes the relative paths and contents of all regular files under root to h, in a
+// consistent order. A missing root is not an error.
+func hashTree(h io.Writer, root string) error {
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "tree %s %s\x00", filepath.Base(root), rel)
+		return hashFile(h, path)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *resultCache) path(key string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(key)+".json")
+}
+
+// get returns the cached result for key, if any.
+func (c *resultCache) get(key string) (*cachedResult, bool) {
+	c.mu.Lock()
+	c.used[key] = true
+	c.mu.Unlock()
+
+	var r cachedResult
+	p, err := ioutil.ReadFile(c.path(key))
+	if err == nil {
+		err = json.Unmarshal(p, &r)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err == nil:
+		c.stats.Hits++
+		return &r, true
+	case os.IsNotExist(err):
+	default:
+		// A corrupt entry is treated as a miss and overwritten.
+		c.stats.Errors++
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// put stores a result under key. Failing to store a result is not fatal, and is only counted in
+// the cache's stats.
+func (c *resultCache) put(key string, r *cachedResult) {
+	err := c.write(key, r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used[key] = true
+	if err != nil {
+		c.stats.Errors++
+		return
+	}
+	c.stats.Stores++
+}
+
+func (c *resultCache) write(key string, r *cachedResult) error {
+	p, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that concurrent runs never read a partial entry.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(p); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// prune removes entries not used since the cache was opened from the scopes of the keys that were
+// used. Entries for other templates, modes, profiles, and configurations are kept.
+func (c *resultCache) prune() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	scopes := map[string]bool{}
+	for key := range c.used {
+		scopes[path.Dir(key)] = true
+	}
+	for scope := range scopes {
+		entries, err := ioutil.ReadDir(filepath.Join(c.dir, scope))
+		if os.IsNotExist(err) {
+			continue // Nothing was stored, as with uncacheable results.
+		} else if err != nil {
+			return err
+		}
+		for _, fi := range entries {
+			name := fi.Name()
+			if fi.IsDir() || !strings.HasSuffix(name, ".json") || c.used[scope+"/"+strings.TrimSuffix(name, ".json")] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(c.dir, scope, name)); err != nil {
+				return err
+			}
+			c.stats.Pruned++
+		}
+	}
+	return nil
+}
+
+// clear removes all entries from the cache.
+func (c *resultCache) clear() error {
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range entries {
+		if err := os.RemoveAll(filepath.Join(c.dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+// tempDir returns a new temporary directory and a function that removes it.
+func tempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "xdeps-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// writeTestFile writes a file under root, creating any directories it needs.
+func writeTestFile(t *testing.T, root, name, content string) string {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func testProfile(t *testing.T, host, target string) *profile {
+	t.Helper()
+	profs, err := parseProfiles(host, []string{target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profs[0]
+}
+
+func TestCacheKey(t *testing.T) {
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	tmpl := writeTestFile(t, root, "srcpkgs/foo/template", "pkgname=foo\n")
+	writeTestFile(t, root, "srcpkgs/foo/files/foo.conf", "a=1\n")
+	native := testProfile(t, "x86_64", "x86_64")
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	c := &resultCache{dir: dir, used: map[string]bool{}}
+	key := func(mode, p string, prof *profile) string {
+		t.Helper()
+		k, err := c.key(mode, p, prof)
+		if err != nil {
+			t.Fatalf("key(%q, %q, %v) error = %v", mode, p, prof, err)
+		}
+		return k
+	}
+	base := key("deps", tmpl, native)
+
+	// Each change sets a different input, returning a function to undo it.
+	changes := []struct {
+		Name string
+		Set  func() func()
+		// SameScope is true if the change is only to the contents of the template's files.
+		SameScope bool
+	}{
+		{"template", func() func() {
+			writeTestFile(t, root, "srcpkgs/foo/template", "pkgname=foo\nrevision=2\n")
+			return func() { writeTestFile(t, root, "srcpkgs/foo/template", "pkgname=foo\n") }
+		}, true},
+		{"files", func() func() {
+			writeTestFile(t, root, "srcpkgs/foo/files/foo.conf", "a=2\n")
+			return func() { writeTestFile(t, root, "srcpkgs/foo/files/foo.conf", "a=1\n") }
+		}, true},
+		{"patches", func() func() {
+			p := writeTestFile(t, root, "srcpkgs/foo/patches/fix.patch", "--- a\n")
+			return func() { os.RemoveAll(filepath.Dir(p)) }
+		}, true},
+		{"options", func() func() {
+			OptionFlags["gtk"] = false
+			return func() { delete(OptionFlags, "gtk") }
+		}, false},
+		{"exec", func() func() {
+			AllowExec = true
+			return func() { AllowExec = false }
+		}, false},
+		{"matrix", func() func() {
+			old := MatrixMax
+			MatrixMax = 4
+			return func() { MatrixMax = old }
+		}, false},
+		{"diag", func() func() {
+			Diagnostics = true
+			return func() { Diagnostics = false }
+		}, false},
+		{"read", func() func() {
+			Sandbox.ReadPaths = []string{"/usr/share"}
+			return func() { Sandbox.ReadPaths = nil }
+		}, false},
+		{"common", func() func() {
+			Common = &commonDir{digest: "1234"}
+			return func() { Common = nil }
+		}, false},
+	}
+	for _, ch := range changes {
+		undo := ch.Set()
+		got := key("deps", tmpl, native)
+		undo()
+		if got == base {
+			t.Errorf("key after changing %s = %q; want a different key", ch.Name, got)
+		}
+		if same := path.Dir(got) == path.Dir(base); same != ch.SameScope {
+			t.Errorf("key after changing %s = %q; want same scope as %q: %t", ch.Name, got, base, ch.SameScope)
+		}
+		if got := key("deps", tmpl, native); got != base {
+			t.Errorf("key after undoing change to %s = %q; want %q", ch.Name, got, base)
+		}
+	}
+
+	others := []struct {
+		Name, Mode, Path string
+		Profile          *profile
+	}{
+		{"mode", "meta", tmpl, native},
+		{"profile", "deps", tmpl, testProfile(t, "x86_64", "aarch64")},
+		{"no profile", "deps", tmpl, nil},
+		{"package", "deps", writeTestFile(t, root, "srcpkgs/bar/template", "pkgname=foo\n"), native},
+	}
+	for _, o := range others {
+		if got := key(o.Mode, o.Path, o.Profile); path.Dir(got) == path.Dir(base) {
+			t.Errorf("key with different %s = %q; want a different scope than %q", o.Name, got, base)
+		}
+	}
+
+	// Files outside of the template, files/, and patches/, and the location of the source tree,
+	// don't affect results.
+	writeTestFile(t, root, "srcpkgs/foo/update", "site=x\n")
+	if got := key("deps", tmpl, native); got != base {
+		t.Errorf("key after adding update file = %q; want %q", got, base)
+	}
+	other, cleanup := tempDir(t)
+	defer cleanup()
+	copied := writeTestFile(t, other, "srcpkgs/foo/template", "pkgname=foo\n")
+	writeTestFile(t, filepath.Dir(copied), "files/foo.conf", "a=1\n")
+	if got := key("deps", copied, native); got != base {
+		t.Errorf("key of copied template = %q; want %q", got, base)
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	foo := writeTestFile(t, root, "srcpkgs/foo/template", "pkgname=foo\nversion=1\n")
+	bar := writeTestFile(t, root, "srcpkgs/bar/template", "pkgname=bar\n")
+	prof := testProfile(t, "x86_64", "x86_64")
+
+	keys := map[string]string{}
+	store := func(c *resultCache, name, mode, p string) {
+		t.Helper()
+		key, err := c.key(mode, p, prof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.put(key, newCachedResult(&templateData{pkgname: "x"}, nil, nil))
+		keys[name] = key
+	}
+
+	old, err := openCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store(old, "foo-1", "deps", foo)
+	store(old, "foo-1-meta", "meta", foo)
+	store(old, "bar", "deps", bar)
+
+	writeTestFile(t, root, "srcpkgs/foo/template", "pkgname=foo\nversion=2\n")
+	c, err := openCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store(c, "foo-2", "deps", foo)
+	if err := c.prune(); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+
+	want := map[string]bool{"foo-1": false, "foo-1-meta": true, "bar": true, "foo-2": true}
+	for name, kept := range want {
+		_, err := os.Stat(c.path(keys[name]))
+		if got := err == nil; got != kept {
+			t.Errorf("after prune, %s entry exists = %t; want %t (stat error: %v)", name, got, kept, err)
+		}
+	}
+	if c.stats.Pruned != 1 {
+		t.Errorf("after prune, stats.Pruned = %d; want 1", c.stats.Pruned)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"go.spiff.io/nxtools/xbps"
+	"go.spiff.io/nxtools/xrepo"
+)
+
+// Statuses of a template compared with repodata.
+const (
+	statusCurrent = "current" // The repository has the template's version and revision.
+	statusAhead   = "ahead"   // The template is newer than the repository and needs building.
+	statusBehind  = "behind"  // The repository is newer than the template.
+	statusMissing = "missing" // The repository has no package by the template's pkgname.
+)
+
+// repoCheck describes how a template compares to the packages in a repository.
+type repoCheck struct {
+	Status     string `json:"status"`
+	Template   string `json:"template"`
+	Repo       string `json:"repo,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// MissingSubpackages lists subpackages of the template with no package in the repository.
+	MissingSubpackages []string `json:"missing_subpackages,omitempty"`
+	// RemovedSubpackages lists packages in the repository built from the template's source
+	// package that the template no longer defines.
+	RemovedSubpackages []string `json:"removed_subpackages,omitempty"`
+}
+
+// checkResults compares each result's template with its packages in rd, recording the outcome in
+// the result's data. Results must have been extracted with extractMeta, and failed results are
+// skipped. Templates that cannot be compared are recorded as failed with a runtime error. If tree
+// is not nil, packages in it are never reported as removed from another template.
+func checkResults(results []depsResult, tree *srcpkgTree, rd *xrepo.RepoData) {
+	bySource := map[string][]string{}
+	for _, g := range rd.Index().BySource() {
+		bySource[g.Source] = g.Names
+	}
+
+	// Packages that have moved to another template are not considered removed.
+	defined := map[string]bool{}
+	if tree != nil {
+		for name := range tree.sourceOf {
+			defined[name] = true
+		}
+	}
+	for i := range results {
+		if results[i].err != nil {
+			continue
+		}
+		defined[results[i].sourceName()] = true
+		for _, sub := range results[i].data.subpackages {
+			defined[sub.Name] = true
+		}
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.err != nil {
+			continue
+		}
+		check, err := checkTemplate(r.data, rd, bySource, defined)
+		if err != nil {
+			r.err = &evalError{Kind: errorRuntime, Message: err.Error()}
+			continue
+		}
+		r.data.check = check
+	}
+}
+
+// checkTemplate compares a template's pkgname, version, and revision with the same package in rd.
+// bySource maps source package names to the names of repository packages built from them, and
+// elsewhere holds the names of packages defined by other templates.
+func checkTemplate(data *templateData, rd *xrepo.RepoData, bySource map[string][]string, elsewhere map[string]bool) (*repoCheck, error) {
+	meta := data.meta
+	if meta.PkgName == "" || meta.Version == "" || meta.Revision == 0 {
+		return nil, fmt.Errorf("template must set pkgname, version, and revision")
+	}
+
+	tmpl := xbps.PkgVer{Name: meta.PkgName, Version: meta.Version, Revision: meta.Revision}
+	check := &repoCheck{
+		Status:   statusMissing,
+		Template: tmpl.String(),
+	}
+
+	if p := rd.Package(meta.PkgName); p != nil {
+		check.Repo = p.PackageVersion
+		check.Repository = p.Repository
+		switch tmpl.Compare(xbps.PkgVer{Name: p.Name, Version: p.Version, Revision: p.Revision}) {
+		case 1:
+			check.Status = statusAhead
+		case -1:
+			check.Status = statusBehind
+		default:
+			check.Status = statusCurrent
+		}
+	}
+
+	defined := map[string]bool{meta.PkgName: true}
+	for _, sub := range data.subpackages {
+		defined[sub.Name] = true
+		if rd.Package(sub.Name) == nil {
+			check.MissingSubpackages = append(check.MissingSubpackages, sub.Name)
+		}
+	}
+	for _, name := range bySource[meta.PkgName] {
+		if !defined[name] && !elsewhere[name] {
+			check.RemovedSubpackages = append(check.RemovedSubpackages, name)
+		}
+	}
+	sort.Strings(check.MissingSubpackages)
+	sort.Strings(check.RemovedSubpackages)
+
+	return check, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"go.spiff.io/nxtools/xrepo"
+	"howett.net/plist"
+)
+
+// testRepoData returns repodata holding the given packages, keyed by name and holding their
+// repodata fields. The pkgver of each package is name-1.0_1 unless set.
+func testRepoData(t *testing.T, pkgs map[string]map[string]interface{}) *xrepo.RepoData {
+	t.Helper()
+	for name, fields := range pkgs {
+		if _, ok := fields["pkgver"]; !ok {
+			fields["pkgver"] = name + "-1.0_1"
+		}
+	}
+	p, err := plist.Marshal(pkgs, plist.XMLFormat)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	rd := xrepo.NewRepoData()
+	if err := rd.ReadRepoIndex(bytes.NewReader(p), ""); err != nil {
+		t.Fatalf("ReadRepoIndex() error = %v", err)
+	}
+	return rd
+}
+
+func checkTestRepoData(t *testing.T) *xrepo.RepoData {
+	return testRepoData(t, map[string]map[string]interface{}{
+		"foo":       {"pkgver": "foo-1.2_1", "source-revisions": "foo:abc"},
+		"libfoo":    {"pkgver": "libfoo-1.2_1", "source-revisions": "foo:abc"},
+		"foo-doc":   {"pkgver": "foo-doc-1.2_1", "source-revisions": "foo:abc"},
+		"foo-moved": {"pkgver": "foo-moved-1.2_1", "source-revisions": "foo:abc"},
+		"bar":       {"pkgver": "bar-2.0_3", "source-revisions": "bar:def"},
+	})
+}
+
+func TestCheckTemplate(t *testing.T) {
+	rd := checkTestRepoData(t)
+	bySource := map[string][]string{}
+	for _, g := range rd.Index().BySource() {
+		bySource[g.Source] = g.Names
+	}
+	elsewhere := map[string]bool{"foo-moved": true}
+
+	cases := []struct {
+		Meta templateMeta
+		Subs []string
+		Want *repoCheck
+	}{
+		{
+			templateMeta{PkgName: "foo", Version: "1.2", Revision: 2},
+			[]string{"libfoo", "foo-devel"},
+			&repoCheck{
+				Status:             statusAhead,
+				Template:           "foo-1.2_2",
+				Repo:               "foo-1.2_1",
+				Repository:         "current",
+				MissingSubpackages: []string{"foo-devel"},
+				RemovedSubpackages: []string{"foo-doc"},
+			},
+		},
+		{
+			templateMeta{PkgName: "bar", Version: "2.0", Revision: 3},
+			nil,
+			&repoCheck{Status: statusCurrent, Template: "bar-2.0_3", Repo: "bar-2.0_3", Repository: "current"},
+		},
+		{
+			templateMeta{PkgName: "bar", Version: "1.9", Revision: 9},
+			nil,
+			&repoCheck{Status: statusBehind, Template: "bar-1.9_9", Repo: "bar-2.0_3", Repository: "current"},
+		},
+		{
+			templateMeta{PkgName: "baz", Version: "1.0", Revision: 1},
+			nil,
+			&repoCheck{Status: statusMissing, Template: "baz-1.0_1"},
+		},
+	}
+	for _, c := range cases {
+		data := &templateData{meta: &c.Meta}
+		for _, name := range c.Subs {
+			data.subpackages = append(data.subpackages, &subpackage{Name: name})
+		}
+		got, err := checkTemplate(data, rd, bySource, elsewhere)
+		if err != nil {
+			t.Errorf("checkTemplate(%s) error = %v", c.Want.Template, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("checkTemplate(%s) = %+v; want %+v", c.Want.Template, got, c.Want)
+		}
+	}
+
+	if _, err := checkTemplate(&templateData{meta: &templateMeta{PkgName: "foo", Version: "1.2"}}, rd, bySource, nil); err == nil {
+		t.Error("checkTemplate without revision: expected error")
+	}
+}
+
+func TestCheckResults(t *testing.T) {
+	rd := checkTestRepoData(t)
+	results := testResults(
+		&templateData{
+			pkgname:     "foo",
+			meta:        &templateMeta{PkgName: "foo", Version: "1.2", Revision: 1},
+			subpackages: []*subpackage{{Name: "libfoo"}},
+		},
+		// foo-moved is now built from its own template, so it isn't removed from foo.
+		&templateData{pkgname: "foo-moved", meta: &templateMeta{PkgName: "foo-moved", Version: "1.3", Revision: 1}},
+		&templateData{pkgname: "broken", meta: &templateMeta{PkgName: "broken"}},
+	)
+	failed := &evalError{Kind: errorParse, Message: "parse error"}
+	results = append(results, depsResult{file: "srcpkgs/bad/template", err: failed})
+
+	// The tree names foo-doc as a subpackage of another template, so it isn't removed either.
+	tree := &srcpkgTree{sourceOf: map[string]string{"foo-doc": "docs"}}
+	checkResults(results, tree, rd)
+
+	want := &repoCheck{Status: statusCurrent, Template: "foo-1.2_1", Repo: "foo-1.2_1", Repository: "current"}
+	if got := results[0].data.check; !reflect.DeepEqual(got, want) {
+		t.Errorf("check(foo) = %+v; want %+v", got, want)
+	}
+	if got := results[1].data.check; got == nil || got.Status != statusAhead {
+		t.Errorf("check(foo-moved) = %+v; want status %s", got, statusAhead)
+	}
+	if err := results[2].err; err == nil || err.Kind != errorRuntime {
+		t.Errorf("check(broken) error = %v; want %s error", err, errorRuntime)
+	}
+	if results[3].err != failed {
+		t.Errorf("check(bad) error = %v; want the original %v", results[3].err, failed)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/interp"
+	"mvdan.cc/sh/syntax"
+)
+
+// Common is the void-packages common/ directory templates are evaluated with, if any.
+var Common *commonDir
+
+// commonDir holds the parsed scripts of a void-packages common/ directory that xbps-src sources
+// around a template.
+type commonDir struct {
+	root string
+	// setup holds the scripts of environment/setup, sourced before a template, in name order.
+	setup []*syntax.File
+	// styles maps build styles to their environment/build-style script, sourced after a template.
+	styles map[string]*syntax.File
+	// helpers maps build helpers to their build-helper script, sourced after a template.
+	helpers map[string]*syntax.File
+	// digest is a hash of the scripts above and the shlibs file, identifying the inputs templates
+	// are evaluated with for caching.
+	digest string
+}
+
+// loadCommon parses the scripts of the void-packages common/ directory at root.
+func loadCommon(root string) (*commonDir, error) {
+	if fi, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", root)
+	}
+
+	c := &commonDir{root: root}
+	var err error
+	if c.setup, err = parseScripts(filepath.Join(root, "environment", "setup")); err != nil {
+		return nil, err
+	}
+	if c.styles, err = parseScriptMap(filepath.Join(root, "environment", "build-style")); err != nil {
+		return nil, err
+	}
+	if c.helpers, err = parseScriptMap(filepath.Join(root, "build-helper")); err != nil {
+		return nil, err
+	}
+	if c.digest, err = digestCommon(root); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// digestCommon returns a hash of the scripts loaded from the common/ directory at root and of its
+// shlibs file, if any.
+func digestCommon(root string) (string, error) {
+	h := sha256.New()
+	for _, dir := range []string{
+		filepath.Join(root, "environment", "setup"),
+		filepath.Join(root, "environment", "build-style"),
+		filepath.Join(root, "build-helper"),
+	} {
+		paths, err := scriptPaths(dir)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "dir %s\x00", strings.TrimPrefix(dir, root))
+		for _, path := range paths {
+			if err := hashFile(h, path); err != nil {
+				return "", err
+			}
+		}
+	}
+	if err := hashFile(h, filepath.Join(root, "shlibs")); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scriptPaths returns the paths of all *.sh files in dir, sorted. A missing dir has no scripts.
+func scriptPaths(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sh"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func parseScripts(dir string) ([]*syntax.File, error) {
+	paths, err := scriptPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*syntax.File, 0, len(paths))
+	for _, path := range paths {
+		file, err := parseFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func parseScriptMap(dir string) (map[string]*syntax.File, error) {
+	paths, err := scriptPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*syntax.File, len(paths))
+	for _, path := range paths {
+		file, err := parseFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		files[strings.TrimSuffix(filepath.Base(path), ".sh")] = file
+	}
+	return files, nil
+}
+
+// environ returns the XBPS_* variables describing the void-packages checkout the common/
+// directory belongs to, and the chroot build environment templates are evaluated as if in.
+func (c *commonDir) environ() []string {
+	dist := filepath.Dir(c.root)
+	return []string{
+		"XBPS_BUILD_ENV=chroot",
+		"XBPS_DISTDIR=" + dist,
+		"XBPS_COMMONDIR=" + c.root,
+		"XBPS_SRCPKGDIR=" + filepath.Join(dist, "srcpkgs"),
+	}
+}
+
+// runSetup sources the environment/setup scripts in runner, as xbps-src does before sourcing a
+// template, ignoring the exit status they leave (see ignoreExitStatus). Functions they define that
+// xdeps implements itself (see shFuncs) are removed, since those would otherwise try to do real
+// work.
+func (c *commonDir) runSetup(ctx context.Context, runner *interp.Runner) error {
+	for _, file := range c.setup {
+		if err := ignoreExitStatus(runner.Run(ctx, file)); err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+	for name := range shFuncs {
+		delete(runner.Funcs, name)
+	}
+	return nil
+}
+
+// runEnvironment sources the environment scripts for the build_style and build_helper of the
+// template runner has run, as xbps-src does after sourcing a template. These commonly append to
+// hostmakedepends and makedepends. Styles and helpers without a script are ignored.
+func (c *commonDir) runEnvironment(ctx context.Context, runner *interp.Runner) error {
+	var files []*syntax.File
+	if file := c.styles[runner.Vars["build_style"].String()]; file != nil {
+		files = append(files, file)
+	}
+	for _, helper := range strings.Fields(runner.Vars["build_helper"].String()) {
+		if file := c.helpers[helper]; file != nil {
+			files = append(files, file)
+		}
+	}
+
+	for _, file := range files {
+		if err := ignoreExitStatus(runner.Run(ctx, file)); err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+	for name := range shFuncs {
+		delete(runner.Funcs, name)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadCommon(t *testing.T) {
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	// The setup script ends in a failed test and defines a helper xdeps implements itself.
+	writeTestFile(t, root, "environment/setup/install.sh", `vopt_if() { echo broken; }
+[ "$XBPS_CROSS_BUILD" ] && echo cross
+`)
+	writeTestFile(t, root, "environment/build-style/python3-module.sh", `hostmakedepends+=" python3"
+makedepends+=" python3"
+`)
+	writeTestFile(t, root, "build-helper/qemu.sh", `hostmakedepends+=" qemu-user-static"
+`)
+
+	c, err := loadCommon(root)
+	if err != nil {
+		t.Fatalf("loadCommon() error = %v", err)
+	}
+	if len(c.setup) != 1 || c.styles["python3-module"] == nil || c.helpers["qemu"] == nil {
+		t.Fatalf("loadCommon() = %+v; want one setup script, a python3-module style, and a qemu helper", c)
+	}
+
+	defer func(old *commonDir) { Common = old }(Common)
+	Common = c
+
+	data, err := evalTestTemplate(t, extractDeps, `pkgname=foo
+build_style=python3-module
+build_helper="qemu gir"
+hostmakedepends="$(vopt_if gtk gtk+3)"
+makedepends="libfoo"
+`)
+	if err != nil {
+		t.Fatalf("extractDeps() error = %v", err)
+	}
+	want := stringLists{
+		"hostmakedepends": {"python3", "qemu-user-static"},
+		"makedepends":     {"libfoo", "python3"},
+	}
+	for name, deps := range want {
+		if got := data.deps[name]; !reflect.DeepEqual(got, deps) {
+			t.Errorf("%s = %q; want %q", name, got, deps)
+		}
+	}
+
+	if _, err := loadCommon(root + "/missing"); err == nil {
+		t.Error("loadCommon(missing): expected error")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+
+	"go.spiff.io/nxtools/xrepo"
+)
+
+// depCheck compares the depends of a template's package with the run_depends and shlib-requires of
+// the same package in a repository.
+type depCheck struct {
+	Package string `json:"package"`
+	// Missing is true if the repository has no package by this name, in which case nothing else
+	// is reported.
+	Missing bool `json:"missing,omitempty"`
+	// Redundant lists template depends on packages that already provide one of the package's
+	// shlib-requires, and so would be added by xbps-src anyway.
+	Redundant []string `json:"redundant,omitempty"`
+	// Untraced lists run_depends entries matching neither a template depend nor a provider of the
+	// package's shlib-requires.
+	Untraced []string `json:"untraced,omitempty"`
+}
+
+// depCheckResults compares the depends of each result's package and subpackages with rd,
+// recording the outcome in the result's data.
+func depCheckResults(results []depsResult, rd *xrepo.RepoData) {
+	for i := range results {
+		data := results[i].data
+		data.depcheck = append(data.depcheck, checkDepends(results[i].sourceName(), data.deps["depends"], rd))
+		for _, sub := range data.subpackages {
+			data.depcheck = append(data.depcheck, checkDepends(sub.Name, sub.Depends, rd))
+		}
+	}
+}
+
+// checkDepends compares a package's template depends with its repository entry in rd.
+func checkDepends(name string, depends []string, rd *xrepo.RepoData) *depCheck {
+	check := &depCheck{Package: name}
+	p := rd.Package(name)
+	if p == nil {
+		check.Missing = true
+		return check
+	}
+
+	shlibDeps := map[string]bool{}
+	for _, so := range p.ShlibRequires {
+		for _, provider := range rd.ShlibProviders(so) {
+			if provider != p {
+				shlibDeps[provider.Name] = true
+			}
+		}
+	}
+
+	explicit := map[string]bool{}
+	for _, dep := range depends {
+		name := depName(dep)
+		explicit[name] = true
+		if shlibDeps[name] {
+			check.Redundant = append(check.Redundant, dep)
+		}
+	}
+
+	for _, dep := range p.RunDepends {
+		name := depName(dep)
+		if !explicit[name] && !shlibDeps[name] {
+			check.Untraced = append(check.Untraced, dep)
+		}
+	}
+
+	sort.Strings(check.Redundant)
+	sort.Strings(check.Untraced)
+	return check
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckDepends(t *testing.T) {
+	rd := testRepoData(t, map[string]map[string]interface{}{
+		"foo": {
+			"run_depends":    []string{"glibc>=2.28_1", "libbar>=1.0_1", "foo-data>=0", "python3>=0"},
+			"shlib-requires": []string{"libc.so.6", "libbar.so.1", "libfoo.so.1"},
+			"shlib-provides": []string{"libfoo.so.1"},
+		},
+		"glibc":    {"shlib-provides": []string{"libc.so.6"}},
+		"libbar":   {"shlib-provides": []string{"libbar.so.1"}},
+		"foo-data": {},
+	})
+
+	cases := []struct {
+		Name    string
+		Depends []string
+		Want    *depCheck
+	}{
+		{
+			"foo",
+			[]string{"libbar>=1.0", "foo-data", "virtual?bar-plugin"},
+			&depCheck{
+				Package:   "foo",
+				Redundant: []string{"libbar>=1.0"},
+				Untraced:  []string{"python3>=0"},
+			},
+		},
+		{
+			"foo",
+			[]string{"foo-data", "python3"},
+			&depCheck{Package: "foo"},
+		},
+		{
+			"baz",
+			[]string{"foo"},
+			&depCheck{Package: "baz", Missing: true},
+		},
+	}
+	for _, c := range cases {
+		if got := checkDepends(c.Name, c.Depends, rd); !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("checkDepends(%q, %q) = %+v; want %+v", c.Name, c.Depends, got, c.Want)
+		}
+	}
+}
+
+func TestDepCheckResults(t *testing.T) {
+	rd := testRepoData(t, map[string]map[string]interface{}{
+		"foo":    {"run_depends": []string{"libfoo>=1.0_1"}},
+		"libfoo": {},
+	})
+	results := testResults(&templateData{
+		pkgname:     "foo",
+		deps:        stringLists{"depends": {"libfoo"}},
+		subpackages: []*subpackage{{Name: "libfoo"}, {Name: "foo-devel", Depends: []string{"libfoo"}}},
+	})
+	depCheckResults(results, rd)
+
+	want := []*depCheck{
+		{Package: "foo"},
+		{Package: "libfoo"},
+		{Package: "foo-devel", Missing: true},
+	}
+	if got := results[0].data.depcheck; !reflect.DeepEqual(got, want) {
+		t.Errorf("depcheck = %+v; want %+v", got, want)
+	}
+}
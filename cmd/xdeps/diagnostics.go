@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// Diagnostics controls whether the stdout and stderr of each template are captured into its record
+// instead of being written to the terminal.
+var Diagnostics = false
+
+// templateOutput captures the stdout and stderr of a template's evaluation.
+type templateOutput struct {
+	// terminal is true if the output is held back to be written to the terminal later, rather
+	// than captured for a record, so that it is marked with the package it came from.
+	terminal bool
+
+	mu     sync.Mutex
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+// diagnostics is the captured output of a template, as reported in its record.
+type diagnostics struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+type outputKey struct{}
+
+// withOutput returns a context carrying the output that templates evaluated with it write to.
+func withOutput(ctx context.Context, out *templateOutput) context.Context {
+	return context.WithValue(ctx, outputKey{}, out)
+}
+
+// outputFrom returns the output carried by ctx, or nil if output is not being captured.
+func outputFrom(ctx context.Context) *templateOutput {
+	out, _ := ctx.Value(outputKey{}).(*templateOutput)
+	return out
+}
+
+// writers returns writers for capturing stdout and stderr.
+func (o *templateOutput) writers() (stdout, stderr io.Writer) {
+	return &lockedWriter{&o.mu, &o.stdout}, &lockedWriter{&o.mu, &o.stderr}
+}
+
+// copyTo writes everything captured so far to dst, or to os.Stdout and os.Stderr if dst is nil.
+func (o *templateOutput) copyTo(dst *templateOutput) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if dst == nil {
+		os.Stdout.Write(o.stdout.Bytes())
+		os.Stderr.Write(o.stderr.Bytes())
+		return
+	}
+	dst.mu.Lock()
+	defer dst.mu.Unlock()
+	dst.stdout.Write(o.stdout.Bytes())
+	dst.stderr.Write(o.stderr.Bytes())
+}
+
+// diagnostics returns everything captured so far, or nil if o is nil.
+func (o *templateOutput) diagnostics() *diagnostics {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return &diagnostics{o.stdout.String(), o.stderr.String()}
+}
+
+// lockedWriter serializes writes to w, since commands run by a template may write to stdout and
+// stderr concurrently.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
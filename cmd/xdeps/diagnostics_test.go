@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/expand"
+	"mvdan.cc/sh/interp"
+	"mvdan.cc/sh/syntax"
+)
+
+func TestExportedEnv(t *testing.T) {
+	file, err := syntax.NewParser().Parse(strings.NewReader("export FOO=1; BAR=2; unset BAZ; run"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	runner, err := interp.New(interp.Env(expand.ListEnviron("BAZ=3", "QUX=4")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.Exec = func(ctx context.Context, path string, args []string) error {
+		mod, _ := interp.FromModuleContext(ctx)
+		got = exportedEnv(mod.Env)
+		return nil
+	}
+	if err := runner.Run(context.Background(), file); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := []string{"FOO=1", "QUX=4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("exportedEnv() = %q; want %q", got, want)
+	}
+
+	if env := exportedEnv(expand.ListEnviron()); env == nil || len(env) != 0 {
+		t.Errorf("exportedEnv(empty) = %#v; want an empty, non-nil slice", env)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	defer func(old bool) { Diagnostics = old }(Diagnostics)
+	Diagnostics = true
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	// Templates declaring build options are evaluated twice, and only the output of the second
+	// evaluation is kept.
+	templates := map[string]string{
+		"plain": "pkgname=plain\necho out\necho err >&2\n",
+		"opts":  "pkgname=opts\nbuild_options=gtk\necho \"out $(vopt_if gtk gtk nogtk)\"\necho err >&2\n",
+	}
+	want := map[string]*diagnostics{
+		"plain": {Stdout: "out\n", Stderr: "err\n"},
+		"opts":  {Stdout: "out nogtk\n", Stderr: "err\n"},
+	}
+	e := &evaluator{extract: extractDeps}
+	for name, src := range templates {
+		path := writeTestFile(t, dir, filepath.Join("srcpkgs", name, "template"), src)
+		r := e.eval(job{0, path, nil})
+		if r.err != nil {
+			t.Errorf("%s: error = %v", name, r.err)
+			continue
+		}
+		if !reflect.DeepEqual(r.diag, want[name]) {
+			t.Errorf("%s: diagnostics = %+v; want %+v", name, r.diag, want[name])
+		}
+	}
+}
+
+func TestHeldBackOutputPrefix(t *testing.T) {
+	if _, err := exec.LookPath("sed"); err != nil {
+		t.Skip("sed not found")
+	}
+	defer func(old bool) { AllowExec = old }(AllowExec)
+	AllowExec = true
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := writeTestFile(t, dir, "srcpkgs/foo/template", "pkgname=foo\nsed -n p missing\n")
+
+	// Output held back for the terminal marks the stderr of host commands with the package, as
+	// when it is written to the terminal directly. Captured output does not.
+	for _, terminal := range []bool{true, false} {
+		out := &templateOutput{terminal: terminal}
+		ctx, sb := newSandbox(context.Background(), path)
+		// sed fails, which fails the template, but its output is kept.
+		_, err := extractDeps(withOutput(ctx, out), path, nil)
+		if err = sb.done(ctx, err); err == nil {
+			t.Fatal("extractDeps(): expected error")
+		}
+		stderr := out.diagnostics().Stderr
+		if prefixed := strings.Contains(stderr, "foo: sed:"); prefixed != terminal || !strings.Contains(stderr, "missing") {
+			t.Errorf("terminal %t: stderr = %q; want prefixed %t", terminal, stderr, terminal)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of errors evaluating a template may fail with.
+const (
+	errorParse      = "parse"       // The template is not valid shell.
+	errorIncomplete = "incomplete"  // The template ends in the middle of a statement.
+	errorExecDenied = "exec-denied" // The template ran a command xdeps does not allow.
+	errorSandbox    = "sandbox"     // The template violated the sandbox policy.
+	errorRuntime    = "runtime"     // Anything else, such as a failing command or msg_error.
+)
+
+// evalError is an error evaluating a single template, as reported in its output record.
+type evalError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Violation is set for errors of kind sandbox.
+	Violation *sandboxViolation `json:"violation,omitempty"`
+}
+
+func (e *evalError) Error() string {
+	return e.Message
+}
+
+// execDenied returns an exec-denied error for cmd.
+func execDenied(cmd, format string, args ...interface{}) error {
+	return &evalError{Kind: errorExecDenied, Message: cmd + ": " + fmt.Sprintf(format, args...)}
+}
+
+// toEvalError converts an error returned by evaluating a template to an *evalError. Errors that
+// are not already an *evalError or *sandboxViolation are runtime errors.
+func toEvalError(err error) *evalError {
+	var (
+		ee *evalError
+		v  *sandboxViolation
+	)
+	switch {
+	case errors.As(err, &ee):
+		return ee
+	case errors.As(err, &v):
+		return &evalError{Kind: errorSandbox, Message: v.Error(), Violation: v}
+	}
+	return &evalError{Kind: errorRuntime, Message: err.Error()}
+}
+
+// errorSummary counts failed evaluations by kind.
+type errorSummary struct {
+	total  int
+	failed map[string]int
+}
+
+func (s *errorSummary) add(err *evalError) {
+	s.total++
+	if err == nil {
+		return
+	}
+	if s.failed == nil {
+		s.failed = map[string]int{}
+	}
+	s.failed[err.Kind]++
+}
+
+// count returns the number of failed evaluations.
+func (s *errorSummary) count() int {
+	n := 0
+	for _, c := range s.failed {
+		n += c
+	}
+	return n
+}
+
+// String describes the failed evaluations, e.g. "3 of 120 evaluations failed: 1 parse, 2 runtime".
+func (s *errorSummary) String() string {
+	kinds := make([]string, 0, len(s.failed))
+	for kind := range s.failed {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for i, kind := range kinds {
+		kinds[i] = fmt.Sprintf("%d %s", s.failed[kind], kind)
+	}
+	return fmt.Sprintf("%d of %d evaluations failed: %s", s.count(), s.total, strings.Join(kinds, ", "))
+}
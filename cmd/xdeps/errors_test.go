@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	cases := []struct {
+		Src, Kind string
+	}{
+		{"pkgname=foo\n", ""},
+		{"pkgname=foo\nfi\n", errorParse},
+		{"pkgname=foo\nif true; then\n", errorIncomplete},
+		{"pkgname='foo\n", errorIncomplete},
+		{"do_install() {\n\tvbin foo\n", errorIncomplete},
+		// A syntax error within an unfinished statement is still a syntax error.
+		{"if true; then\n)\nfi\n", errorParse},
+	}
+	for i, c := range cases {
+		path := writeTestFile(t, dir, filepath.Join("t", string(rune('a'+i))), c.Src)
+		_, err := parseFile(path)
+		if c.Kind == "" {
+			if err != nil {
+				t.Errorf("parseFile(%q) error = %v", c.Src, err)
+			}
+			continue
+		}
+		ee, ok := err.(*evalError)
+		if !ok || ee.Kind != c.Kind {
+			t.Errorf("parseFile(%q) error = %#v; want %s error", c.Src, err, c.Kind)
+		}
+	}
+}
+
+func TestErrorSummary(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	templates := []struct {
+		Src, Kind string
+	}{
+		{"pkgname=foo\n", ""},
+		{"pkgname=foo\nfi\n", errorParse},
+		{"pkgname=foo\nif true; then\n", errorIncomplete},
+		{"pkgname=foo\nmsg_error 'no such version'\n", errorRuntime},
+		{"pkgname=foo\ncurl -o x https://example.org\n", errorExecDenied},
+		{"pkgname=foo\nread x < /etc/passwd\n", errorSandbox},
+		{"pkgname=foo\nfalse\n", ""},
+	}
+	e := &evaluator{extract: extractDeps}
+	var summary errorSummary
+	for i, tmpl := range templates {
+		path := writeTestFile(t, dir, filepath.Join("srcpkgs", string(rune('a'+i)), "template"), tmpl.Src)
+		r := e.eval(job{i, path, nil})
+		summary.add(r.err)
+		switch {
+		case tmpl.Kind == "" && r.err != nil:
+			t.Errorf("%q: error = %v", tmpl.Src, r.err)
+		case tmpl.Kind != "" && (r.err == nil || r.err.Kind != tmpl.Kind):
+			t.Errorf("%q: error = %v; want %s error", tmpl.Src, r.err, tmpl.Kind)
+		}
+		if tmpl.Kind == errorSandbox && (r.err == nil || r.err.Violation == nil || r.err.Violation.Kind != violationRead) {
+			t.Errorf("%q: error = %v; want %s violation", tmpl.Src, r.err, violationRead)
+		}
+	}
+
+	const want = "5 of 7 evaluations failed: 1 exec-denied, 1 incomplete, 1 parse, 1 runtime, 1 sandbox"
+	if got := summary.String(); got != want {
+		t.Errorf("summary = %q; want %q", got, want)
+	}
+	if status := exitStatus(&summary, false); status != 1 {
+		t.Errorf("exitStatus() = %d; want 1", status)
+	}
+
+	var ok errorSummary
+	ok.add(nil)
+	if status := exitStatus(&ok, false); ok.count() != 0 || status != 0 {
+		t.Errorf("exitStatus() with no failures = %d, count %d; want 0, 0", status, ok.count())
+	}
+	if status := exitStatus(&ok, true); status != 1 {
+		t.Errorf("exitStatus(failed) = %d; want 1", status)
+	}
+}
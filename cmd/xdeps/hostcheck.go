@@ -0,0 +1,270 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// findUnsafeOpts are the find primaries that run commands, delete files, or write to files.
+var findUnsafeOpts = newStringSet(
+	"-exec", "-execdir", "-ok", "-okdir", "-delete",
+	"-fls", "-fprint", "-fprint0", "-fprintf",
+)
+
+// unsafeUsage returns a description of the first construct in a command line that would let the
+// command write files, run other commands, or read files other than its file operands once run
+// on the host, or "" if there is none. Scripts that cannot be checked, such as sed and awk script
+// files, are reported as well.
+func unsafeUsage(cmd string, args []string) string {
+	switch cmd {
+	case "find":
+		for _, arg := range args {
+			if findUnsafeOpts.Has(arg) {
+				return arg
+			}
+		}
+	case "sed":
+		line := parseCommandLine(cmd, operandSpecs[cmd], args)
+		if strings.IndexByte(line.opts, 'i') != -1 || containsString(line.long, "in-place") {
+			return "in-place editing"
+		} else if strings.IndexByte(line.opts, 'f') != -1 || containsString(line.long, "file") {
+			return "script file"
+		}
+		for _, script := range line.scripts {
+			if what := sedUnsafe(script); what != "" {
+				return what
+			}
+		}
+	case "awk":
+		line := parseCommandLine(cmd, operandSpecs[cmd], args)
+		// gawk also loads source files with -i and extensions with -l.
+		if strings.IndexAny(line.opts, "fEil") != -1 {
+			return "script file"
+		}
+		for _, name := range line.long {
+			switch name {
+			case "file", "exec", "include", "load":
+				return "script file"
+			}
+		}
+		for _, prog := range line.scripts {
+			if what := awkUnsafe(prog); what != "" {
+				return what
+			}
+		}
+	}
+	return ""
+}
+
+// sedUnsafe returns a description of the first command in a sed script that reads or writes a
+// file or runs a command (r, R, w, W, e, or an s command with the w or e flag), or "" if there is
+// none. Commands it does not recognize are reported as well.
+func sedUnsafe(script string) string {
+	for s := script; ; {
+		s = strings.TrimLeft(s, " \t\n;")
+		if s == "" {
+			return ""
+		}
+
+		var ok bool
+		if s, ok = skipSedAddress(s); !ok {
+			return "malformed address in " + strconv.Quote(script)
+		}
+		if s = strings.TrimLeft(s, " \t"); strings.HasPrefix(s, ",") {
+			if s, ok = skipSedAddress(strings.TrimLeft(s[1:], " \t")); !ok {
+				return "malformed address in " + strconv.Quote(script)
+			}
+		}
+		s = strings.TrimLeft(strings.TrimLeft(s, " \t"), "!")
+		if s = strings.TrimLeft(s, " \t"); s == "" {
+			return "missing command in " + strconv.Quote(script)
+		}
+
+		c := s[0]
+		s = s[1:]
+		switch c {
+		case '{', '}', '=', 'd', 'D', 'g', 'G', 'h', 'H', 'n', 'N', 'p', 'P', 'x', 'z', 'F':
+		case 'l', 'L', 'q', 'Q':
+			s = strings.TrimLeft(strings.TrimLeft(s, " \t"), "0123456789")
+		case 'b', 't', 'T', ':', 'v':
+			s = skipToAny(s, ";\n")
+		case '#':
+			s = skipToAny(s, "\n")
+		case 'a', 'i', 'c':
+			s = skipSedText(s)
+		case 's', 'y':
+			if len(s) == 0 || s[0] == '\\' || s[0] == '\n' {
+				return "malformed " + string(c) + " command in " + strconv.Quote(script)
+			}
+			delim := s[0]
+			s = s[1:]
+			for i := 0; i < 2; i++ {
+				_, n, ok := sedField(s, delim)
+				if !ok {
+					return "malformed " + string(c) + " command in " + strconv.Quote(script)
+				}
+				s = s[n:]
+			}
+			if c == 'y' {
+				break
+			}
+			end := strings.IndexAny(s, ";\n}")
+			if end == -1 {
+				end = len(s)
+			}
+			if i := strings.IndexAny(s[:end], "we"); i != -1 {
+				return "sed s flag " + strconv.Quote(s[i:i+1])
+			}
+			s = s[end:]
+		case 'r', 'R', 'w', 'W', 'e':
+			return "sed " + string(c) + " command"
+		default:
+			return "unrecognized sed command " + strconv.Quote(string(c))
+		}
+	}
+}
+
+// skipSedAddress returns s without the sed address it begins with, if any, and whether the
+// address was well-formed.
+func skipSedAddress(s string) (string, bool) {
+	switch {
+	case s == "":
+	case s[0] == '$':
+		s = s[1:]
+	case s[0] >= '0' && s[0] <= '9', s[0] == '+', s[0] == '~':
+		s = strings.TrimLeft(s[1:], "0123456789")
+		if strings.HasPrefix(s, "~") {
+			s = strings.TrimLeft(s[1:], "0123456789")
+		}
+	case s[0] == '/', s[0] == '\\':
+		delim, start := byte('/'), 1
+		if s[0] == '\\' {
+			if len(s) < 2 {
+				return s, false
+			}
+			delim, start = s[1], 2
+		}
+		_, n, ok := sedField(s[start:], delim)
+		if !ok {
+			return s, false
+		}
+		s = strings.TrimLeft(s[start+n:], "IM")
+	}
+	return s, true
+}
+
+// skipSedText returns s without the text of an a, i, or c command, which runs to the first
+// newline not escaped by a backslash.
+func skipSedText(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n':
+			return s[i:]
+		}
+	}
+	return ""
+}
+
+// skipToAny returns s from the first of chars in it, or "" if it has none.
+func skipToAny(s, chars string) string {
+	if i := strings.IndexAny(s, chars); i != -1 {
+		return s[i:]
+	}
+	return ""
+}
+
+// awkKeywords are awk keywords that may be followed by a regular expression literal.
+var awkKeywords = newStringSet(
+	"BEGIN", "END", "case", "delete", "do", "else", "exit", "for", "function", "if", "in",
+	"print", "printf", "return", "while",
+)
+
+// awkUnsafe returns a description of the first construct in an awk program that reads or writes
+// a file or runs a command (getline, system, pipes, and output redirection), or "" if there is
+// none. gawk's @ directives are reported as well.
+func awkUnsafe(prog string) string {
+	var (
+		// operand is true after a token that a division operator may follow, so that a slash
+		// after any other token begins a regular expression.
+		operand bool
+		// print is true within a print or printf statement, and depth counts the parentheses
+		// open since it began, so that a > at depth 0 is known to be a redirection.
+		print bool
+		depth int
+	)
+	for i := 0; i < len(prog); i++ {
+		c := prog[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\\':
+			continue
+		case c == '#':
+			for i+1 < len(prog) && prog[i+1] != '\n' {
+				i++
+			}
+		case c == '\n' || c == ';' || c == '{' || c == '}':
+			print, operand = false, false
+		case c == '"':
+			for i++; i < len(prog) && prog[i] != '"'; i++ {
+				if prog[i] == '\\' {
+					i++
+				}
+			}
+			operand = true
+		case c == '/' && !operand:
+			for i++; i < len(prog) && prog[i] != '/'; i++ {
+				switch prog[i] {
+				case '\\':
+					i++
+				case '[':
+					// A slash within a bracket expression does not end the regular expression.
+					for i++; i < len(prog) && prog[i] != ']'; i++ {
+					}
+				}
+			}
+			operand = true
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			start := i
+			for i+1 < len(prog) && isAlnum(prog[i+1]) {
+				i++
+			}
+			word := prog[start : i+1]
+			switch word {
+			case "getline", "system":
+				return "awk " + word
+			case "print", "printf":
+				print, depth = true, 0
+			}
+			operand = !awkKeywords.Has(word)
+		case '0' <= c && c <= '9' || c == '.':
+			operand = true
+		case c == '(':
+			depth++
+			operand = false
+		case c == ')':
+			depth--
+			operand = true
+		case c == ']':
+			operand = true
+		case c == '|':
+			if i+1 < len(prog) && prog[i+1] == '|' {
+				i++
+				operand = false
+				continue
+			}
+			return "awk pipe"
+		case c == '>' && print && depth <= 0:
+			return "awk output redirection"
+		case c == '@':
+			return "awk @ directive"
+		default:
+			operand = false
+		}
+	}
+	return ""
+}
+
+func isAlnum(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
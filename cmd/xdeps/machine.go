@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// machine describes an XBPS host or target machine (XBPS_MACHINE / XBPS_TARGET_MACHINE).
+type machine struct {
+	name     string
+	triplet  string
+	libc     string
+	wordSize int
+	endian   string
+	// noAtomic8 is true for machines without 8-byte atomic operations.
+	noAtomic8 bool
+}
+
+var machines = map[string]*machine{}
+
+func init() {
+	for _, m := range []*machine{
+		{"x86_64", "x86_64-unknown-linux-gnu", "glibc", 64, "le", false},
+		{"x86_64-musl", "x86_64-linux-musl", "musl", 64, "le", false},
+		{"i686", "i686-pc-linux-gnu", "glibc", 32, "le", false},
+		{"i686-musl", "i686-linux-musl", "musl", 32, "le", false},
+		{"aarch64", "aarch64-linux-gnu", "glibc", 64, "le", false},
+		{"aarch64-musl", "aarch64-linux-musl", "musl", 64, "le", false},
+		{"armv7l", "armv7l-linux-gnueabihf", "glibc", 32, "le", false},
+		{"armv7l-musl", "armv7l-linux-musleabihf", "musl", 32, "le", false},
+		{"armv6l", "arm-linux-gnueabihf", "glibc", 32, "le", false},
+		{"armv6l-musl", "arm-linux-musleabihf", "musl", 32, "le", false},
+		{"armv5tel", "arm-linux-gnueabi", "glibc", 32, "le", true},
+		{"armv5tel-musl", "arm-linux-musleabi", "musl", 32, "le", true},
+		{"ppc64le", "powerpc64le-linux-gnu", "glibc", 64, "le", false},
+		{"ppc64le-musl", "powerpc64le-linux-musl", "musl", 64, "le", false},
+		{"ppc64", "powerpc64-linux-gnu", "glibc", 64, "be", false},
+		{"ppc64-musl", "powerpc64-linux-musl", "musl", 64, "be", false},
+		{"ppc", "powerpc-linux-gnu", "glibc", 32, "be", true},
+		{"ppc-musl", "powerpc-linux-musl", "musl", 32, "be", true},
+		{"mips-musl", "mips-linux-musl", "musl", 32, "be", true},
+		{"mipsel-musl", "mipsel-linux-musl", "musl", 32, "le", true},
+	} {
+		machines[m.name] = m
+	}
+}
+
+// lookupMachine returns the machine with the given name.
+func lookupMachine(name string) (*machine, error) {
+	m := machines[name]
+	if m == nil {
+		names := make([]string, 0, len(machines))
+		for n := range machines {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown machine %q (known machines: %s)", name, strings.Join(names, ", "))
+	}
+	return m, nil
+}
+
+// profile is a host and target machine pair that templates are evaluated under.
+type profile struct {
+	host   *machine
+	target *machine
+}
+
+// cross returns whether the profile describes a cross build.
+func (p *profile) cross() bool {
+	return p.host != p.target
+}
+
+// String returns the name of the profile's target machine.
+func (p *profile) String() string {
+	return p.target.name
+}
+
+// environ returns the XBPS_* variables set by xbps-src for the profile, in the form name=value.
+func (p *profile) environ() []string {
+	h, t := p.host, p.target
+	env := []string{
+		"XBPS_MACHINE=" + h.name,
+		"XBPS_ENDIAN=" + h.endian,
+		"XBPS_LIBC=" + h.libc,
+		"XBPS_WORDSIZE=" + strconv.Itoa(h.wordSize),
+		"XBPS_TRIPLET=" + h.triplet,
+		"XBPS_TARGET_MACHINE=" + t.name,
+		"XBPS_TARGET_ENDIAN=" + t.endian,
+		"XBPS_TARGET_LIBC=" + t.libc,
+		"XBPS_TARGET_WORDSIZE=" + strconv.Itoa(t.wordSize),
+	}
+	if h.noAtomic8 {
+		env = append(env, "XBPS_NO_ATOMIC8=1")
+	}
+	if t.noAtomic8 {
+		env = append(env, "XBPS_TARGET_NO_ATOMIC8=1")
+	}
+	if p.cross() {
+		env = append(env,
+			"CROSS_BUILD="+t.name,
+			"XBPS_CROSS_BUILD="+t.name,
+			"XBPS_CROSS_TRIPLET="+t.triplet,
+			"XBPS_CROSS_BASE=/usr/"+t.triplet,
+		)
+	}
+	return env
+}
+
+// parseProfiles returns a profile for each of the named target machines, built on the named host
+// machine.
+func parseProfiles(host string, targets []string) ([]*profile, error) {
+	hm, err := lookupMachine(host)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]*profile, 0, len(targets))
+	for _, name := range targets {
+		tm, err := lookupMachine(name)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, &profile{host: hm, target: tm})
+	}
+	return profiles, nil
+}
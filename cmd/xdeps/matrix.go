@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// MatrixMax is the maximum number of build option combinations evaluated per template in matrix
+// mode. Templates with more combinations are sampled.
+var MatrixMax = 256
+
+// optionMatrix summarizes a template's dependencies across combinations of its build options.
+type optionMatrix struct {
+	// Combinations is the number of option combinations evaluated, including conflicts.
+	Combinations int `json:"combinations"`
+	// Conflicts is the number of combinations rejected by vopt_conflict.
+	Conflicts int `json:"conflicts"`
+	// Sampled is true if there were more than MatrixMax combinations and only a sample of them
+	// was evaluated.
+	Sampled bool                   `json:"sampled"`
+	Depends map[string]*matrixDeps `json:"depends"`
+}
+
+// matrixDeps describes the dependencies of a single *depends variable across option
+// combinations.
+type matrixDeps struct {
+	// Always holds dependencies present under every evaluated combination.
+	Always []string `json:"always"`
+	// Conditional holds dependencies present under only some combinations.
+	Conditional []*conditionalDep `json:"conditional,omitempty"`
+}
+
+// conditionalDep is a dependency present under only some option combinations.
+type conditionalDep struct {
+	Dep string `json:"dep"`
+	// When holds options enabled in every combination the dependency appears in.
+	When []string `json:"when,omitempty"`
+	// Unless holds options disabled in every combination the dependency appears in.
+	Unless []string `json:"unless,omitempty"`
+	// Combinations is the number of combinations the dependency appears in.
+	Combinations int `json:"combinations"`
+}
+
+// extractMatrix evaluates the template at path under combinations of its build options and
+// summarizes which dependencies are unconditional and which depend on options. Options given with
+// -o are fixed and not varied. Combinations rejected by vopt_conflict are skipped.
+func extractMatrix(ctx context.Context, path string, prof *profile) (*templateData, error) {
+	file, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	env := templateEnv(prof)
+
+	runner, err := runTemplate(ctx, file, env)
+	if err != nil {
+		return nil, err
+	}
+
+	_, buildOpts := templateOptions(func(name string) string {
+		return runner.Vars[name].String()
+	}, OptionFlags)
+
+	var (
+		free  []string
+		fixed = optionSet{} // Options enabled with -o.
+	)
+	defaults := make([]bool, 0, len(buildOpts))
+	for _, opt := range buildOpts {
+		if on, ok := OptionFlags[opt.Name]; !ok {
+			free = append(free, opt.Name)
+			defaults = append(defaults, opt.Enabled)
+		} else if on {
+			fixed.Add(opt.Name)
+		}
+	}
+
+	combos, sampled := optionCombinations(defaults, MatrixMax)
+	m := &optionMatrix{Sampled: sampled}
+
+	var (
+		// seen[variable][dep] holds the combinations (as indices into results) a dep appears in.
+		seen    = map[string]map[string][]int{}
+		results []optionSet
+	)
+	for _, combo := range combos {
+		m.Combinations++
+
+		opts := optionSet{}
+		for opt := range fixed {
+			opts.Add(opt)
+		}
+		for i, on := range combo {
+			if on {
+				opts.Add(free[i])
+			}
+		}
+
+		// Each combination is a separate evaluation, so its output is limited on its own.
+		sandboxFrom(ctx).resetOutput()
+		runner, err := runWithOptions(ctx, file, env, opts)
+		var conflict *optionConflictError
+		if errors.As(err, &conflict) {
+			m.Conflicts++
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		index := len(results)
+		results = append(results, opts)
+		for name, deps := range collectDeps(runner) {
+			if seen[name] == nil {
+				seen[name] = map[string][]int{}
+			}
+			for _, dep := range deps {
+				if in := seen[name][dep]; len(in) == 0 || in[len(in)-1] != index {
+					seen[name][dep] = append(in, index)
+				}
+			}
+		}
+	}
+
+	m.Depends = make(map[string]*matrixDeps, len(seen))
+	for name, deps := range seen {
+		md := &matrixDeps{Always: []string{}}
+		for dep, in := range deps {
+			if len(in) == len(results) {
+				md.Always = append(md.Always, dep)
+				continue
+			}
+			md.Conditional = append(md.Conditional, conditionsOf(dep, in, results, buildOpts))
+		}
+		sort.Strings(md.Always)
+		sort.Slice(md.Conditional, func(i, j int) bool {
+			return md.Conditional[i].Dep < md.Conditional[j].Dep
+		})
+		m.Depends[name] = md
+	}
+
+	return &templateData{pkgname: runner.Vars["pkgname"].String(), options: buildOpts, matrix: m}, nil
+}
+
+// conditionsOf returns the options that are enabled (or disabled) in every combination a
+// dependency appears in (in), excluding options enabled (or disabled) in every evaluated
+// combination (all).
+func conditionsOf(dep string, in []int, all []optionSet, opts []buildOption) *conditionalDep {
+	cd := &conditionalDep{Dep: dep, Combinations: len(in)}
+	for _, opt := range opts {
+		var onIn, onAll int
+		for _, i := range in {
+			if all[i].Has(opt.Name) {
+				onIn++
+			}
+		}
+		for _, set := range all {
+			if set.Has(opt.Name) {
+				onAll++
+			}
+		}
+
+		switch {
+		case onIn == len(in) && onAll < len(all):
+			cd.When = append(cd.When, opt.Name)
+		case onIn == 0 && onAll > 0:
+			cd.Unless = append(cd.Unless, opt.Name)
+		}
+	}
+	return cd
+}
+
+// optionCombinations returns combinations of n options, where n is the length of defaults. If
+// there are no more than max combinations, all of them are returned. Otherwise, a sample of max
+// combinations is returned: the defaults, all options enabled, all options disabled, each option
+// toggled from its default, and then random combinations chosen with a fixed seed so that results
+// are reproducible.
+func optionCombinations(defaults []bool, max int) (combos [][]bool, sampled bool) {
+	n := len(defaults)
+	if n < 31 && 1<<uint(n) <= max {
+		for bits := 0; bits < 1<<uint(n); bits++ {
+			combo := make([]bool, n)
+			for i := range combo {
+				combo[i] = bits&(1<<uint(i)) != 0
+			}
+			combos = append(combos, combo)
+		}
+		return combos, false
+	}
+
+	seen := map[string]bool{}
+	add := func(combo []bool) {
+		key := fmt.Sprint(combo)
+		if len(combos) < max && !seen[key] {
+			seen[key] = true
+			combos = append(combos, combo)
+		}
+	}
+
+	add(append([]bool(nil), defaults...))
+	allOn, allOff := make([]bool, n), make([]bool, n)
+	for i := range allOn {
+		allOn[i] = true
+	}
+	add(allOn)
+	add(allOff)
+	for i := range defaults {
+		combo := append([]bool(nil), defaults...)
+		combo[i] = !combo[i]
+		add(combo)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for attempts := 0; len(combos) < max && attempts < max*4; attempts++ {
+		combo := make([]bool, n)
+		for i := range combo {
+			combo[i] = rng.Intn(2) == 1
+		}
+		add(combo)
+	}
+	return combos, true
+}
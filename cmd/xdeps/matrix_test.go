@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestOptionCombinationsAll(t *testing.T) {
+	combos, sampled := optionCombinations([]bool{true, false}, 4)
+	want := [][]bool{{false, false}, {true, false}, {false, true}, {true, true}}
+	if sampled || !reflect.DeepEqual(combos, want) {
+		t.Errorf("optionCombinations(2 options, 4) = %v, %t; want %v, false", combos, sampled, want)
+	}
+
+	combos, sampled = optionCombinations(nil, 4)
+	if sampled || !reflect.DeepEqual(combos, [][]bool{{}}) {
+		t.Errorf("optionCombinations(no options, 4) = %v, %t; want [[]], false", combos, sampled)
+	}
+}
+
+func TestOptionCombinationsSampled(t *testing.T) {
+	defaults := []bool{true, false, true, false}
+	cases := []struct {
+		Max  int
+		Want [][]bool
+	}{
+		{1, [][]bool{defaults}},
+		{
+			7,
+			[][]bool{
+				defaults,
+				{true, true, true, true},
+				{false, false, false, false},
+				{false, false, true, false},
+				{true, true, true, false},
+				{true, false, false, false},
+				{true, false, true, true},
+			},
+		},
+	}
+	for _, c := range cases {
+		combos, sampled := optionCombinations(defaults, c.Max)
+		if !sampled || !reflect.DeepEqual(combos, c.Want) {
+			t.Errorf("optionCombinations(%v, %d) = %v, %t; want %v, true", defaults, c.Max, combos, sampled, c.Want)
+		}
+	}
+
+	// Larger samples are filled with distinct random combinations, the same on every call.
+	combos, _ := optionCombinations(defaults, 12)
+	again, _ := optionCombinations(defaults, 12)
+	if len(combos) != 12 || !reflect.DeepEqual(combos, again) {
+		t.Errorf("optionCombinations(%v, 12) = %v then %v; want 12 identical combinations", defaults, combos, again)
+	}
+	seen := map[string]bool{}
+	for _, combo := range combos {
+		key := fmt.Sprint(combo)
+		if seen[key] {
+			t.Errorf("optionCombinations(%v, 12) repeats %v", defaults, combo)
+		}
+		seen[key] = true
+	}
+}
+
+func TestConditionsOf(t *testing.T) {
+	opts := []buildOption{{Name: "gtk"}, {Name: "qt"}, {Name: "ssl"}}
+	// ssl is enabled in every combination, so it never conditions a dependency.
+	all := []optionSet{
+		newStringSet("ssl"),
+		newStringSet("gtk", "ssl"),
+		newStringSet("qt", "ssl"),
+		newStringSet("gtk", "qt", "ssl"),
+	}
+	cases := []struct {
+		In   []int
+		Want *conditionalDep
+	}{
+		{[]int{1, 3}, &conditionalDep{Dep: "dep", When: []string{"gtk"}, Combinations: 2}},
+		{[]int{0, 2}, &conditionalDep{Dep: "dep", Unless: []string{"gtk"}, Combinations: 2}},
+		{[]int{1}, &conditionalDep{Dep: "dep", When: []string{"gtk"}, Unless: []string{"qt"}, Combinations: 1}},
+		{[]int{3}, &conditionalDep{Dep: "dep", When: []string{"gtk", "qt"}, Combinations: 1}},
+		{[]int{0, 3}, &conditionalDep{Dep: "dep", Combinations: 2}},
+	}
+	for _, c := range cases {
+		if got := conditionsOf("dep", c.In, all, opts); !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("conditionsOf(%v) = %+v; want %+v", c.In, got, c.Want)
+		}
+	}
+}
+
+func TestExtractMatrix(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	// Each evaluation writes 9 bytes, which fits in the output limit, but all of them do not.
+	path := writeTestFile(t, dir, "srcpkgs/foo/template", `pkgname=foo
+build_options="a b c"
+vopt_conflict a b
+makedepends="base $(vopt_if a liba) $(vopt_if c libc)"
+echo 12345678
+`)
+	defer func(max int64) { Sandbox.MaxOutput = max }(Sandbox.MaxOutput)
+	Sandbox.MaxOutput = 20
+
+	ctx, sb := newSandbox(context.Background(), path)
+	data, err := extractMatrix(withOutput(ctx, &templateOutput{}), path, nil)
+	if err = sb.done(ctx, err); err != nil {
+		t.Fatalf("extractMatrix() error = %v", err)
+	}
+
+	m := data.matrix
+	if m.Combinations != 8 || m.Conflicts != 2 || m.Sampled {
+		t.Errorf("extractMatrix() = %d combinations, %d conflicts, sampled %t; want 8, 2, false",
+			m.Combinations, m.Conflicts, m.Sampled)
+	}
+	want := &matrixDeps{
+		Always: []string{"base"},
+		Conditional: []*conditionalDep{
+			{Dep: "liba", When: []string{"a"}, Unless: []string{"b"}, Combinations: 2},
+			{Dep: "libc", When: []string{"c"}, Combinations: 3},
+		},
+	}
+	if got := m.Depends["makedepends"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("extractMatrix() makedepends = %+v; want %+v", got, want)
+	}
+}
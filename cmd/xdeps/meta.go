@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.spiff.io/nxtools/xbps"
+	"mvdan.cc/sh/interp"
+)
+
+// templateMeta holds the standard fields of a template.
+type templateMeta struct {
+	PkgName     string          `json:"pkgname"`
+	Version     string          `json:"version"`
+	Revision    int             `json:"revision"`
+	BuildStyle  string          `json:"build_style,omitempty"`
+	BuildHelper []string        `json:"build_helper,omitempty"`
+	ShortDesc   string          `json:"short_desc,omitempty"`
+	Maintainer  xbps.Maintainer `json:"maintainer"`
+	License     string          `json:"license,omitempty"`
+	Homepage    string          `json:"homepage,omitempty"`
+	Distfiles   []string        `json:"distfiles,omitempty"`
+	Checksum    []string        `json:"checksum,omitempty"`
+	Archs       []string        `json:"archs,omitempty"`
+	// NoCross is true if nocross is set. Templates may set nocross to a reason instead of "yes",
+	// in which case NoCrossReason holds it.
+	NoCross       bool      `json:"nocross,omitempty"`
+	NoCrossReason string    `json:"nocross_reason,omitempty"`
+	Restricted    bool      `json:"restricted,omitempty"`
+	ConfFiles     []string  `json:"conf_files,omitempty"`
+	MakeDirs      []makeDir `json:"make_dirs,omitempty"`
+}
+
+// makeDir is a single entry of a template's make_dirs.
+type makeDir struct {
+	Path  string `json:"path"`
+	Mode  string `json:"mode"`
+	User  string `json:"user"`
+	Group string `json:"group"`
+}
+
+// extractMeta evaluates the template at path as extractDeps does, and also returns its standard
+// fields (see templateMeta).
+func extractMeta(ctx context.Context, path string, prof *profile) (*templateData, error) {
+	data, runner, err := evalTemplate(ctx, path, prof)
+	if err != nil {
+		return nil, err
+	}
+	if data.meta, err = readMeta(runner); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readMeta reads the standard fields of a template from an interpreter that has run it.
+func readMeta(runner *interp.Runner) (*templateMeta, error) {
+	str := func(name string) string {
+		return runner.Vars[name].String()
+	}
+	fields := func(name string) []string {
+		return strings.Fields(str(name))
+	}
+
+	meta := &templateMeta{
+		PkgName:     str("pkgname"),
+		Version:     str("version"),
+		BuildStyle:  str("build_style"),
+		BuildHelper: fields("build_helper"),
+		ShortDesc:   str("short_desc"),
+		Maintainer:  xbps.ParseMaintainer(str("maintainer")),
+		License:     str("license"),
+		Homepage:    str("homepage"),
+		Distfiles:   fields("distfiles"),
+		Checksum:    fields("checksum"),
+		Archs:       fields("archs"),
+		Restricted:  str("restricted") != "",
+		ConfFiles:   fields("conf_files"),
+	}
+
+	if rev := str("revision"); rev != "" {
+		n, err := strconv.Atoi(rev)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("revision is not a valid integer >= 1: %q", rev)
+		}
+		meta.Revision = n
+	}
+
+	if nocross := str("nocross"); nocross != "" {
+		meta.NoCross = true
+		if nocross != "yes" {
+			meta.NoCrossReason = nocross
+		}
+	}
+
+	dirs := fields("make_dirs")
+	if len(dirs)%4 != 0 {
+		return nil, fmt.Errorf("make_dirs must consist of path, mode, user, and group: %q", str("make_dirs"))
+	}
+	for i := 0; i < len(dirs); i += 4 {
+		meta.MakeDirs = append(meta.MakeDirs, makeDir{dirs[i], dirs[i+1], dirs[i+2], dirs[i+3]})
+	}
+
+	return meta, nil
+}
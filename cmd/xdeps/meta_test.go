@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.spiff.io/nxtools/xbps"
+)
+
+func TestExtractMeta(t *testing.T) {
+	data, err := evalTestTemplate(t, extractMeta, `pkgname=foo
+version=1.2.3
+revision=4
+build_style=gnu-configure
+build_helper="qemu gir"
+short_desc="Foo utilities"
+maintainer="Jane Doe <jane@example.org>"
+license="MIT"
+homepage="https://foo.org"
+distfiles="https://foo.org/foo-${version}.tar.gz"
+checksum=abc123
+archs="x86_64* ~*-musl"
+nocross="needs to run tests"
+conf_files="/etc/foo.conf /etc/foo.d/*.conf"
+make_dirs="/var/lib/foo 0750 foo foo
+	/var/log/foo 0755 root root"
+`)
+	if err != nil {
+		t.Fatalf("extractMeta() error = %v", err)
+	}
+	want := &templateMeta{
+		PkgName:       "foo",
+		Version:       "1.2.3",
+		Revision:      4,
+		BuildStyle:    "gnu-configure",
+		BuildHelper:   []string{"qemu", "gir"},
+		ShortDesc:     "Foo utilities",
+		Maintainer:    xbps.Maintainer{Name: "Jane Doe", Email: "jane@example.org"},
+		License:       "MIT",
+		Homepage:      "https://foo.org",
+		Distfiles:     []string{"https://foo.org/foo-1.2.3.tar.gz"},
+		Checksum:      []string{"abc123"},
+		Archs:         []string{"x86_64*", "~*-musl"},
+		NoCross:       true,
+		NoCrossReason: "needs to run tests",
+		ConfFiles:     []string{"/etc/foo.conf", "/etc/foo.d/*.conf"},
+		MakeDirs: []makeDir{
+			{"/var/lib/foo", "0750", "foo", "foo"},
+			{"/var/log/foo", "0755", "root", "root"},
+		},
+	}
+	if !reflect.DeepEqual(data.meta, want) {
+		t.Errorf("extractMeta() meta = %+v; want %+v", data.meta, want)
+	}
+}
+
+func TestExtractMetaErrors(t *testing.T) {
+	cases := []struct {
+		Template, Want string
+	}{
+		{"pkgname=foo\nrevision=0\n", "revision is not a valid integer"},
+		{"pkgname=foo\nrevision=1a\n", "revision is not a valid integer"},
+		{"pkgname=foo\nmake_dirs=\"/var/lib/foo 0750 foo\"\n", "make_dirs must consist of"},
+	}
+	for _, c := range cases {
+		_, err := evalTestTemplate(t, extractMeta, c.Template)
+		if err == nil || !strings.Contains(err.Error(), c.Want) {
+			t.Errorf("extractMeta(%q) error = %v; want %q", c.Template, err, c.Want)
+		}
+	}
+
+	// nocross=yes has no reason, and a template without a revision is not an error here.
+	data, err := evalTestTemplate(t, extractMeta, "pkgname=foo\nnocross=yes\n")
+	if err != nil {
+		t.Fatalf("extractMeta() error = %v", err)
+	}
+	if m := data.meta; !m.NoCross || m.NoCrossReason != "" || m.Revision != 0 {
+		t.Errorf("extractMeta() meta = %+v; want nocross without a reason and no revision", m)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 type optionSet map[string]struct{}
 
-var Options = optionSet{}
-
 func newStringSet(vals ...string) optionSet {
 	o := optionSet{}
 	for _, v := range vals {
@@ -27,17 +30,104 @@ func (s optionSet) Remove(k string) {
 	delete(s, k)
 }
 
-func (s optionSet) String() string {
-	return ""
+// OptionFlags holds the build options enabled or disabled with -o. They are applied on top of
+// each template's build_options_default.
+var OptionFlags = optionFlags{}
+
+// optionFlags is a flag.Value recording build options enabled (opt) or disabled (~opt) on the
+// command line, in the same comma-separated form accepted by xbps-src -o.
+type optionFlags map[string]bool
+
+func (f optionFlags) String() string {
+	opts := make([]string, 0, len(f))
+	for opt, enabled := range f {
+		if !enabled {
+			opt = "~" + opt
+		}
+		opts = append(opts, opt)
+	}
+	sort.Strings(opts)
+	return strings.Join(opts, ",")
 }
 
-func (s optionSet) Set(k string) error {
+func (f optionFlags) Set(k string) error {
 	for _, opt := range strings.Split(k, ",") {
 		if strings.HasPrefix(opt, "~") {
-			s.Remove(opt[1:])
-		} else {
-			s.Add(opt)
+			f[opt[1:]] = false
+		} else if opt != "" {
+			f[opt] = true
 		}
 	}
 	return nil
 }
+
+// buildOption describes one of a template's build_options.
+type buildOption struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Desc    string `json:"desc,omitempty"`
+}
+
+// templateOptions computes a template's enabled build options from its build_options,
+// build_options_default, and desc_option_* variables (looked up by vars) and the options given by
+// flags. Flags naming options the template does not declare are ignored, since flags apply to
+// every template evaluated (see undeclaredOptions).
+func templateOptions(vars func(name string) string, flags optionFlags) (optionSet, []buildOption) {
+	declared := strings.Fields(vars("build_options"))
+	known := newStringSet(declared...)
+
+	enabled := newStringSet(strings.Fields(vars("build_options_default"))...)
+	for opt, on := range flags {
+		if !known.Has(opt) {
+			continue
+		} else if on {
+			enabled.Add(opt)
+		} else {
+			enabled.Remove(opt)
+		}
+	}
+
+	opts := make([]buildOption, len(declared))
+	for i, opt := range declared {
+		opts[i] = buildOption{
+			Name:    opt,
+			Enabled: enabled.Has(opt),
+			Desc:    vars("desc_option_" + opt),
+		}
+	}
+	return enabled, opts
+}
+
+// undeclaredOptions returns an error naming the options in flags that are not in declared, the
+// build options of every template evaluated.
+func undeclaredOptions(flags optionFlags, declared optionSet) error {
+	var unknown []string
+	for opt := range flags {
+		if !declared.Has(opt) {
+			unknown = append(unknown, opt)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown build options: %s (not in the build_options of any template)",
+		strings.Join(unknown, ", "))
+}
+
+type optionsKey struct{}
+
+// withOptions returns a context carrying the set of enabled build options used by vopt_*
+// functions.
+func withOptions(ctx context.Context, opts optionSet) context.Context {
+	return context.WithValue(ctx, optionsKey{}, opts)
+}
+
+// optionsFrom returns the set of enabled build options carried by ctx. If ctx carries no options,
+// an empty set is returned.
+func optionsFrom(ctx context.Context) optionSet {
+	if opts, ok := ctx.Value(optionsKey{}).(optionSet); ok {
+		return opts
+	}
+	return optionSet{}
+}
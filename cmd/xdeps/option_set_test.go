@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateOptions(t *testing.T) {
+	vars := map[string]string{
+		"build_options":         "gtk x11 wayland",
+		"build_options_default": "x11 wayland",
+		"desc_option_gtk":       "Enable GTK",
+	}
+	lookup := func(name string) string { return vars[name] }
+
+	cases := []struct {
+		Flags   optionFlags
+		Enabled []string
+	}{
+		{optionFlags{}, []string{"wayland", "x11"}},
+		{optionFlags{"gtk": true, "wayland": false}, []string{"gtk", "x11"}},
+		// Options the template doesn't declare are ignored.
+		{optionFlags{"qt": true, "x11": false}, []string{"wayland"}},
+	}
+	for _, c := range cases {
+		enabled, opts := templateOptions(lookup, c.Flags)
+		if want := newStringSet(c.Enabled...); !reflect.DeepEqual(enabled, want) {
+			t.Errorf("templateOptions(%v) enabled = %v; want %v", c.Flags, enabled, want)
+		}
+		if len(opts) != 3 || opts[0].Name != "gtk" || opts[0].Desc != "Enable GTK" {
+			t.Errorf("templateOptions(%v) options = %+v; want gtk, x11, wayland", c.Flags, opts)
+			continue
+		}
+		for _, opt := range opts {
+			if opt.Enabled != enabled.Has(opt.Name) {
+				t.Errorf("templateOptions(%v) option %s enabled = %t; want %t", c.Flags, opt.Name, opt.Enabled, !opt.Enabled)
+			}
+		}
+	}
+}
+
+func TestUndeclaredOptions(t *testing.T) {
+	declared := newStringSet("gtk", "x11")
+	if err := undeclaredOptions(optionFlags{"gtk": true, "x11": false}, declared); err != nil {
+		t.Errorf("undeclaredOptions with declared options: error = %v", err)
+	}
+	err := undeclaredOptions(optionFlags{"gtk": true, "qt": true, "doc": false}, declared)
+	if want := "unknown build options: doc, qt (not in the build_options of any template)"; err == nil || err.Error() != want {
+		t.Errorf("undeclaredOptions with undeclared options: error = %v; want %q", err, want)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.spiff.io/nxtools/depgraph"
+	"go.spiff.io/nxtools/xbps"
+)
+
+// depKinds maps *depends variables to the kind of dependency they describe.
+var depKinds = map[string]depgraph.Kind{
+	"hostmakedepends": depgraph.HostMake,
+	"makedepends":     depgraph.Make,
+	"checkdepends":    depgraph.Check,
+	"depends":         depgraph.Run,
+}
+
+const virtualPrefix = "virtual?"
+
+// depName returns the package name of a template dependency, which may be a version pattern and
+// may be prefixed with "virtual?".
+func depName(dep string) string {
+	name := strings.TrimPrefix(dep, virtualPrefix)
+	if pat, err := xbps.ParsePattern(name); err == nil {
+		name = pat.Name
+	}
+	return name
+}
+
+// sourceName returns the name of the source package a result was evaluated from.
+func (r *depsResult) sourceName() string {
+	if r.data.pkgname != "" {
+		return r.data.pkgname
+	}
+	return filepath.Base(filepath.Dir(r.file))
+}
+
+// sourceGraph is a dependency graph between source packages built from evaluated templates.
+type sourceGraph struct {
+	*depgraph.Graph
+	// sourceOf maps source package and subpackage names to source package names.
+	sourceOf map[string]string
+	// unresolved maps source packages to dependencies that could not be mapped to a source
+	// package.
+	unresolved map[string][]string
+}
+
+// newSourceGraph maps the dependencies of evaluated templates back to the source packages that
+// provide them and returns the resulting graph. Dependencies may name a source package or any of
+// its subpackages (as declared by *_package functions or, if tree is not nil, by subpackage
+// symlinks) and may include a version pattern. Subpackage depends are treated as run dependencies
+// of their source package, and dependencies of a source package on itself are ignored.
+func newSourceGraph(results []depsResult, tree *srcpkgTree) *sourceGraph {
+	sourceOf := map[string]string{}
+	if tree != nil {
+		for name, source := range tree.sourceOf {
+			sourceOf[name] = source
+		}
+	}
+	for i := range results {
+		source := results[i].sourceName()
+		sourceOf[source] = source
+		for _, sub := range results[i].data.subpackages {
+			sourceOf[sub.Name] = source
+		}
+	}
+
+	g := &sourceGraph{
+		Graph:      depgraph.New(),
+		sourceOf:   sourceOf,
+		unresolved: map[string][]string{},
+	}
+	for i := range results {
+		r := &results[i]
+		source := r.sourceName()
+		g.AddNode(source)
+
+		add := func(dep string, kind depgraph.Kind) {
+			target, ok := sourceOf[depName(dep)]
+			if !ok {
+				if !containsString(g.unresolved[source], dep) {
+					g.unresolved[source] = append(g.unresolved[source], dep)
+				}
+				return
+			}
+			if target != source {
+				g.AddEdge(source, target, kind)
+			}
+		}
+
+		for name, deps := range r.data.deps {
+			kind, ok := depKinds[name]
+			if !ok {
+				continue
+			}
+			for _, dep := range deps {
+				add(dep, kind)
+			}
+		}
+		for _, sub := range r.data.subpackages {
+			for _, dep := range sub.Depends {
+				add(dep, depgraph.Run)
+			}
+		}
+	}
+
+	for _, deps := range g.unresolved {
+		sort.Strings(deps)
+	}
+	return g
+}
+
+// buildGraph returns the graph of what must be built before each source package: its host and
+// make dependencies, along with everything those depend on at run time (since they must be
+// installed to build the package).
+func (g *sourceGraph) buildGraph() *depgraph.Graph {
+	run := g.Filter(depgraph.Run)
+	// closures caches the run-time closure of each build dependency, since many packages share
+	// the same ones.
+	closures := map[string][]string{}
+	build := depgraph.New()
+	for _, n := range g.Nodes() {
+		build.AddNode(n)
+		for _, e := range g.Out(n) {
+			if e.Kind != depgraph.HostMake && e.Kind != depgraph.Make {
+				continue
+			}
+			build.AddEdge(n, e.To, e.Kind)
+			closure, ok := closures[e.To]
+			if !ok {
+				closure = run.ShortestPaths(e.To).Reachable()
+				closures[e.To] = closure
+			}
+			for _, dep := range closure {
+				if dep != e.To && dep != n {
+					build.AddEdge(n, dep, depgraph.Run)
+				}
+			}
+		}
+	}
+	return build
+}
+
+// buildOrder is the output of the order mode.
+type buildOrder struct {
+	Order      []string            `json:"order"`
+	Cycles     [][]string          `json:"cycles,omitempty"`
+	Unresolved map[string][]string `json:"unresolved,omitempty"`
+}
+
+// orderResults returns the order source packages must be built in, along with any build
+// dependency cycles that prevent some packages from being ordered.
+func orderResults(results []depsResult, tree *srcpkgTree) *buildOrder {
+	g := newSourceGraph(results, tree)
+	order, cycles := g.buildGraph().TopoSort()
+	if order == nil {
+		order = []string{}
+	}
+	return &buildOrder{
+		Order:      order,
+		Cycles:     cycles,
+		Unresolved: g.unresolved,
+	}
+}
+
+// graphResults writes the dependency graph between source packages to w in the given format. If
+// roots are given, only source packages reachable from them are included. Roots may name source
+// packages or subpackages.
+func graphResults(w io.Writer, format string, results []depsResult, tree *srcpkgTree, roots []string) error {
+	g := newSourceGraph(results, tree)
+	if len(roots) == 0 {
+		return g.Export(w, format)
+	}
+
+	sources := make([]string, len(roots))
+	for i, r := range roots {
+		source, ok := g.sourceOf[r]
+		if !ok {
+			return fmt.Errorf("no such package: %s", r)
+		}
+		sources[i] = source
+	}
+	return g.Subgraph(sources...).Export(w, format)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
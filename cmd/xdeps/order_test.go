@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"go.spiff.io/nxtools/depgraph"
+)
+
+// testResults returns results for templates with the given source package names and data.
+func testResults(data ...*templateData) []depsResult {
+	results := make([]depsResult, len(data))
+	for i, d := range data {
+		results[i] = depsResult{index: i, file: "srcpkgs/" + d.pkgname + "/template", data: d}
+	}
+	return results
+}
+
+func orderTestResults() []depsResult {
+	return testResults(
+		&templateData{
+			pkgname: "app",
+			deps: stringLists{
+				"hostmakedepends": {"pkg-config"},
+				"makedepends":     {"libfoo-devel>=1.0_1", "zlib-devel"},
+				"depends":         {"virtual?app-data"},
+			},
+		},
+		&templateData{
+			pkgname: "foo",
+			deps:    stringLists{"makedepends": {"zlib-devel"}},
+			subpackages: []*subpackage{
+				{Name: "libfoo-devel", Depends: []string{"foo>=0", "libfoo"}},
+				{Name: "libfoo", Depends: []string{"glibc"}},
+			},
+		},
+		&templateData{pkgname: "pkg-config", deps: stringLists{"depends": {"foo"}}},
+		&templateData{pkgname: "zlib", subpackages: []*subpackage{{Name: "zlib-devel"}}},
+	)
+}
+
+func TestNewSourceGraph(t *testing.T) {
+	g := newSourceGraph(orderTestResults(), nil)
+
+	want := []depgraph.Edge{
+		{From: "app", To: "foo", Kind: depgraph.Make},
+		{From: "app", To: "pkg-config", Kind: depgraph.HostMake},
+		{From: "app", To: "zlib", Kind: depgraph.Make},
+		{From: "foo", To: "zlib", Kind: depgraph.Make},
+		{From: "pkg-config", To: "foo", Kind: depgraph.Run},
+	}
+	if got := g.Edges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Edges() = %v; want %v", got, want)
+	}
+
+	unresolved := map[string][]string{
+		"app": {"virtual?app-data"},
+		"foo": {"glibc"},
+	}
+	if !reflect.DeepEqual(g.unresolved, unresolved) {
+		t.Errorf("unresolved = %q; want %q", g.unresolved, unresolved)
+	}
+
+	// Subpackage symlinks in the tree name source packages not declared by *_package functions.
+	tree := &srcpkgTree{sourceOf: map[string]string{"app-data": "app"}}
+	if g := newSourceGraph(orderTestResults(), tree); len(g.unresolved["app"]) != 0 {
+		t.Errorf("unresolved[app] = %q with tree; want none", g.unresolved["app"])
+	}
+}
+
+func TestOrderResults(t *testing.T) {
+	order := orderResults(orderTestResults(), nil)
+	// pkg-config only needs foo at run time, so it can be built first, but app needs foo
+	// installed along with pkg-config.
+	if want := []string{"pkg-config", "zlib", "foo", "app"}; !reflect.DeepEqual(order.Order, want) {
+		t.Errorf("orderResults() order = %q; want %q", order.Order, want)
+	}
+	if len(order.Cycles) != 0 {
+		t.Errorf("orderResults() cycles = %q; want none", order.Cycles)
+	}
+	if want := []string{"virtual?app-data"}; !reflect.DeepEqual(order.Unresolved["app"], want) {
+		t.Errorf("orderResults() unresolved[app] = %q; want %q", order.Unresolved["app"], want)
+	}
+
+	// A cycle through a run dependency of a host dependency prevents ordering the packages in
+	// it, but not their dependencies.
+	cyclic := testResults(
+		&templateData{pkgname: "a", deps: stringLists{"hostmakedepends": {"b"}}},
+		&templateData{pkgname: "b", deps: stringLists{"depends": {"c"}}},
+		&templateData{pkgname: "c", deps: stringLists{"makedepends": {"a"}}},
+		&templateData{pkgname: "d"},
+	)
+	order = orderResults(cyclic, nil)
+	if want := []string{"b", "d"}; !reflect.DeepEqual(order.Order, want) {
+		t.Errorf("orderResults(cyclic) order = %q; want %q", order.Order, want)
+	}
+	if len(order.Cycles) != 1 {
+		t.Errorf("orderResults(cyclic) cycles = %q; want 1 cycle", order.Cycles)
+	}
+}
+
+func TestGraphResults(t *testing.T) {
+	var buf bytes.Buffer
+	if err := graphResults(&buf, "dot", orderTestResults(), nil, []string{"libfoo"}); err != nil {
+		t.Fatalf("graphResults() error = %v", err)
+	}
+	want := `digraph deps {
+	"foo";
+	"zlib";
+	"foo" -> "zlib" [label="make"];
+}
+`
+	if buf.String() != want {
+		t.Errorf("graphResults(libfoo) = %q; want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := graphResults(&buf, "dot", orderTestResults(), nil, nil); err != nil {
+		t.Fatalf("graphResults() error = %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("->")); n != 5 {
+		t.Errorf("graphResults() wrote %d edges; want 5:\n%s", n, buf.String())
+	}
+
+	if err := graphResults(&buf, "dot", orderTestResults(), nil, []string{"nope"}); err == nil {
+		t.Error("graphResults(nope): expected error")
+	}
+}
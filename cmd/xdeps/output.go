@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// schemaVersion is the version of the record schema, written with every record. It must be
+// incremented whenever a field is removed or changes meaning; adding fields does not require it.
+const schemaVersion = 1
+
+// Formats records may be written in.
+const (
+	formatJSON = "json" // One JSON record per line.
+	formatCSV  = "csv"  // One row per file, variable, and dependency.
+	formatMake = "make" // Makefile variable assignments, for use with include.
+	formatSh   = "sh"   // Shell variable assignments, for use with . (source).
+)
+
+// recordFormats lists the formats accepted by newRecordWriter.
+var recordFormats = []string{formatJSON, formatCSV, formatMake, formatSh}
+
+// errRecordFormat is returned by newRecordWriter for unknown formats.
+var errRecordFormat = errors.New("unknown output format")
+
+// record is the output of evaluating a single template for a single profile, as written in the
+// json format. Records of failed evaluations only have the fields up to and including Error.
+type record struct {
+	Schema int    `json:"schema"`
+	File   string `json:"file"`
+	// Symlinks holds the names of the subpackage symlinks in srcpkgs that point to the
+	// template's directory, if templates were found through -tree.
+	Symlinks    []string     `json:"symlinks,omitempty"`
+	Arch        string       `json:"arch,omitempty"`
+	Host        string       `json:"host,omitempty"`
+	Diagnostics *diagnostics `json:"diagnostics,omitempty"`
+	Error       *evalError   `json:"error,omitempty"`
+
+	Template *templateMeta `json:"template,omitempty"`
+	// Deps maps *depends variables to their entries, as strings or, with -patterns, as
+	// structured patterns (see depEntry).
+	Deps           interface{}   `json:"deps,omitempty"`
+	BuildOptions   []buildOption `json:"build_options,omitempty"`
+	Subpackages    interface{}   `json:"subpackages,omitempty"`
+	InvalidDepends []invalidDep  `json:"invalid_depends,omitempty"`
+	Actions        []action      `json:"actions,omitempty"`
+	Matrix         *optionMatrix `json:"matrix,omitempty"`
+	Repo           *repoCheck    `json:"repo,omitempty"`
+	DepCheck       []*depCheck   `json:"depcheck,omitempty"`
+
+	// pkgname, deps, and subpackages are used by formats other than json, which only describe
+	// depends.
+	pkgname     string
+	deps        stringLists
+	subpackages []*subpackage
+}
+
+// newRecord returns the record of a result. If tree is not nil, the record includes the
+// template's symlinks.
+func newRecord(r *depsResult, tree *srcpkgTree) *record {
+	rec := &record{
+		Schema:      schemaVersion,
+		File:        r.file,
+		Diagnostics: r.diag,
+		Error:       r.err,
+	}
+	if tree != nil {
+		rec.Symlinks = tree.links[filepath.Base(filepath.Dir(r.file))]
+	}
+	if r.profile != nil {
+		rec.Arch = r.profile.String()
+		if r.profile.cross() {
+			rec.Host = r.profile.host.name
+		}
+	}
+	if r.err != nil {
+		return rec
+	}
+
+	data := r.data
+	rec.pkgname, rec.deps, rec.subpackages = r.sourceName(), data.deps, data.subpackages
+	rec.Template = data.meta
+	rec.BuildOptions = data.options
+	rec.Actions = data.actions
+	rec.Matrix = data.matrix
+	rec.Repo = data.check
+	rec.DepCheck = data.depcheck
+	// Deps is only set if the mode extracts depends (matrix mode doesn't), since a nil map in an
+	// interface would still be written as null.
+	if Patterns {
+		pats := parsePatterns(data.pkgname, data)
+		if data.deps != nil {
+			rec.Deps = pats.deps
+		}
+		if len(pats.subpackages) > 0 {
+			rec.Subpackages = pats.subpackages
+		}
+		rec.InvalidDepends = pats.invalid
+	} else {
+		if data.deps != nil {
+			rec.Deps = data.deps
+		}
+		if len(data.subpackages) > 0 {
+			rec.Subpackages = data.subpackages
+		}
+	}
+	return rec
+}
+
+// depRow is a single dependency of a package, as written in the csv, make, and sh formats.
+type depRow struct {
+	pkgname  string
+	variable string
+	deps     []string
+}
+
+// depRows returns the depends of a record's template and its subpackages, in output order.
+func (rec *record) depRows() []depRow {
+	rows := make([]depRow, 0, len(rec.deps)+len(rec.subpackages))
+	for _, k := range rec.deps.Keys() {
+		rows = append(rows, depRow{rec.pkgname, k, rec.deps[k]})
+	}
+	for _, sub := range rec.subpackages {
+		rows = append(rows, depRow{sub.Name, "depends", sub.Depends})
+	}
+	return rows
+}
+
+// recordWriter writes records in a particular format.
+type recordWriter interface {
+	// write writes a record. Formats other than json cannot describe failed evaluations, and
+	// skip records with an Error.
+	write(rec *record) error
+}
+
+// newRecordWriter returns a recordWriter writing to w in the given format.
+func newRecordWriter(w io.Writer, format string) (recordWriter, error) {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return jsonWriter{enc}, nil
+	case formatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case formatMake:
+		return &assignWriter{w: w, header: "XDEPS_SCHEMA := %d\n", name: makeName, assign: makeAssign}, nil
+	case formatSh:
+		return &assignWriter{w: w, header: "XDEPS_SCHEMA=%d\n", name: shName, assign: shAssign}, nil
+	}
+	return nil, fmt.Errorf("%w: %q (expected one of %s)", errRecordFormat, format, strings.Join(recordFormats, ", "))
+}
+
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w jsonWriter) write(rec *record) error {
+	return w.enc.Encode(rec)
+}
+
+// csvWriter writes one row per file, arch, package, variable, and dependency, after a header row.
+// Each record is flushed as soon as it is written.
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+var csvHeader = []string{"file", "arch", "pkgname", "variable", "dep"}
+
+func (w *csvWriter) write(rec *record) error {
+	if !w.header {
+		w.header = true
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	if rec.Error != nil {
+		return nil
+	}
+	for _, row := range rec.depRows() {
+		for _, dep := range row.deps {
+			if err := w.w.Write([]string{rec.File, rec.Arch, row.pkgname, row.variable, dep}); err != nil {
+				return err
+			}
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// assignWriter writes records as variable assignments, one per package and variable, after a
+// header assigning XDEPS_SCHEMA. Variables are named pkgname_variable, or pkgname_arch_variable
+// if the record has an arch. If name is set, it maps those to the names written. Since it may map
+// distinct names to the same variable, writing a record whose variables would overwrite another's
+// is an error.
+type assignWriter struct {
+	w      io.Writer
+	header string
+	name   func(string) string
+	assign func(w *bytes.Buffer, name string, values []string)
+	wrote  bool
+	// names maps the variables written to the names they were written for.
+	names map[string]string
+}
+
+// errAssignCollision is returned by assignWriter when two variables map to the same name.
+var errAssignCollision = errors.New("variable name collision")
+
+func (w *assignWriter) write(rec *record) error {
+	var buf bytes.Buffer
+	if !w.wrote {
+		fmt.Fprintf(&buf, w.header, schemaVersion)
+	}
+	if rec.Error == nil {
+		prefix := rec.pkgname
+		if rec.Arch != "" {
+			prefix += "_" + rec.Arch
+		}
+		vars := []string{prefix + "_file"}
+		values := [][]string{{rec.File}}
+		for _, row := range rec.depRows() {
+			name := row.pkgname
+			if rec.Arch != "" {
+				name += "_" + rec.Arch
+			}
+			vars = append(vars, name+"_"+row.variable)
+			values = append(values, row.deps)
+		}
+
+		names, err := w.varNames(vars)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rec.File, err)
+		}
+		buf.WriteString("# " + rec.File + "\n")
+		for i, name := range names {
+			w.assign(&buf, name, values[i])
+		}
+	}
+	w.wrote = true
+	_, err := buf.WriteTo(w.w)
+	return err
+}
+
+// varNames returns the variable names to write for vars, recording them as written. If any would
+// overwrite a variable written for a different name, nothing is recorded and an error is returned.
+func (w *assignWriter) varNames(vars []string) ([]string, error) {
+	if w.name == nil {
+		return vars, nil
+	}
+	if w.names == nil {
+		w.names = map[string]string{}
+	}
+	names := make([]string, len(vars))
+	added := map[string]string{}
+	for i, v := range vars {
+		name := w.name(v)
+		prev, ok := w.names[name]
+		if !ok {
+			prev, ok = added[name]
+		}
+		if ok && prev != v {
+			return nil, fmt.Errorf("%w: %s and %s are both written as %s", errAssignCollision, prev, v, name)
+		}
+		added[name], names[i] = v, name
+	}
+	for name, v := range added {
+		w.names[name] = v
+	}
+	return names, nil
+}
+
+// makeAssign writes a simply-expanded Makefile variable assignment. Dollar signs and number signs
+// in values are escaped, since make would otherwise expand them or treat them as comments.
+func makeAssign(w *bytes.Buffer, name string, values []string) {
+	r := strings.NewReplacer("$", "$$", "#", `\#`)
+	w.WriteString(name + " :=")
+	for _, v := range values {
+		w.WriteString(" " + r.Replace(v))
+	}
+	w.WriteByte('\n')
+}
+
+// makeName returns name with characters that make does not allow in variable names, or that it
+// would expand, replaced with underscores. As with shName, assignWriter rejects distinct names
+// that map to the same variable.
+func makeName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch c {
+		case '=', ':', '#', '$', '(', ')', '{', '}', ' ', '\t', '\n', '\r', '\v', '\f':
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// shAssign writes a POSIX shell variable assignment of values separated by spaces.
+func shAssign(w *bytes.Buffer, name string, values []string) {
+	w.WriteString(name + "=" + shQuote(strings.Join(values, " ")) + "\n")
+}
+
+// shName returns name with characters not valid in shell variable names replaced with
+// underscores. Distinct names may map to the same variable (e.g. foo-devel and foo_devel), which
+// assignWriter rejects.
+func shName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func shQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewRecordDeps(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Data     *templateData
+		Patterns bool
+		Want     string
+	}{
+		{"deps", &templateData{deps: stringLists{"depends": {"foo"}}}, false, `"deps":{"depends":["foo"]}`},
+		{"no deps", &templateData{deps: stringLists{}}, false, `"deps":{}`},
+		{"matrix", &templateData{matrix: &optionMatrix{}}, false, ""},
+		{"patterns", &templateData{deps: stringLists{}}, true, `"deps":{}`},
+		{"matrix patterns", &templateData{matrix: &optionMatrix{}}, true, ""},
+	}
+	defer func(p bool) { Patterns = p }(Patterns)
+	for _, c := range cases {
+		Patterns = c.Patterns
+		p, err := json.Marshal(newRecord(&depsResult{file: "srcpkgs/foo/template", data: c.Data}, nil))
+		if err != nil {
+			t.Fatalf("%s: Marshal error = %v", c.Name, err)
+		}
+		if c.Want == "" {
+			if strings.Contains(string(p), `"deps"`) {
+				t.Errorf("%s: record = %s; want no deps", c.Name, p)
+			}
+		} else if !strings.Contains(string(p), c.Want) {
+			t.Errorf("%s: record = %s; want %s", c.Name, p, c.Want)
+		}
+	}
+}
+
+func TestAssignWriter(t *testing.T) {
+	rec := func(pkgname, arch string, deps stringLists, subs ...*subpackage) *record {
+		return &record{File: "srcpkgs/" + pkgname + "/template", Arch: arch, pkgname: pkgname, deps: deps, subpackages: subs}
+	}
+
+	var buf bytes.Buffer
+	w, err := newRecordWriter(&buf, formatSh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := []*record{
+		rec("gtk+3", "x86_64", stringLists{"makedepends": {"glib-devel", "it's"}},
+			&subpackage{Name: "gtk+3-devel", Depends: []string{"gtk+3>=3.24_1"}}),
+		rec("foo", "", stringLists{}),
+		{File: "srcpkgs/bad/template", Error: &evalError{Kind: errorRuntime, Message: "failed"}},
+		rec("foo", "", stringLists{}), // Writing the same variables again is not a collision.
+	}
+	for _, r := range recs {
+		if err := w.write(r); err != nil {
+			t.Fatalf("write(%s) error = %v", r.File, err)
+		}
+	}
+	want := `XDEPS_SCHEMA=1
+# srcpkgs/gtk+3/template
+gtk_3_x86_64_file='srcpkgs/gtk+3/template'
+gtk_3_x86_64_makedepends='glib-devel it'\''s'
+gtk_3_devel_x86_64_depends='gtk+3>=3.24_1'
+# srcpkgs/foo/template
+foo_file='srcpkgs/foo/template'
+# srcpkgs/foo/template
+foo_file='srcpkgs/foo/template'
+`
+	if got := buf.String(); got != want {
+		t.Errorf("sh output = %q; want %q", got, want)
+	}
+
+	buf.Reset()
+	collisions := []*record{
+		rec("foo_devel", "", stringLists{"depends": {"a"}}),
+		rec("foo", "", stringLists{}, &subpackage{Name: "foo-devel", Depends: []string{"b"}}),
+	}
+	if err := w.write(collisions[0]); err != nil {
+		t.Fatalf("write(%s) error = %v", collisions[0].File, err)
+	}
+	n := buf.Len()
+	if err := w.write(collisions[1]); !errors.Is(err, errAssignCollision) {
+		t.Errorf("write(%s) error = %v; want %v", collisions[1].File, err, errAssignCollision)
+	}
+	if buf.Len() != n {
+		t.Errorf("write with collision wrote %q", buf.String()[n:])
+	}
+
+	// Make variables may hold any of the characters in package names, so those don't collide,
+	// but other characters are replaced and checked the same way.
+	buf.Reset()
+	if w, err = newRecordWriter(&buf, formatMake); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range collisions {
+		if err := w.write(r); err != nil {
+			t.Fatalf("make write(%s) error = %v", r.File, err)
+		}
+	}
+	if err := w.write(rec("foo:bar", "", stringLists{"depends": {"$x #y"}})); err != nil {
+		t.Fatalf("make write(foo:bar) error = %v", err)
+	}
+	want = `XDEPS_SCHEMA := 1
+# srcpkgs/foo_devel/template
+foo_devel_file := srcpkgs/foo_devel/template
+foo_devel_depends := a
+# srcpkgs/foo/template
+foo_file := srcpkgs/foo/template
+foo-devel_depends := b
+# srcpkgs/foo:bar/template
+foo_bar_file := srcpkgs/foo:bar/template
+foo_bar_depends := $$x \#y
+`
+	if got := buf.String(); got != want {
+		t.Errorf("make output = %q; want %q", got, want)
+	}
+
+	n = buf.Len()
+	if err := w.write(rec("foo=bar", "", stringLists{})); !errors.Is(err, errAssignCollision) {
+		t.Errorf("make write(foo=bar) error = %v; want %v", err, errAssignCollision)
+	}
+	if buf.Len() != n {
+		t.Errorf("make write with collision wrote %q", buf.String()[n:])
+	}
+}
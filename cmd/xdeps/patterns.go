@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"go.spiff.io/nxtools/xbps"
+)
+
+// Patterns controls whether depends entries are output as structured patterns (see depEntry)
+// instead of strings.
+var Patterns = false
+
+// Contexts a dependency is installed in.
+const (
+	contextHost   = "host"   // Installed in the build environment for the host machine.
+	contextTarget = "target" // Installed for, or built against, the target machine.
+)
+
+// depContexts maps *depends variables to the context their dependencies are installed in.
+// Variables not listed are assumed to be target dependencies.
+var depContexts = map[string]string{
+	"hostmakedepends": contextHost,
+	"checkdepends":    contextHost,
+}
+
+// depEntry is a single, parsed entry of a *depends variable.
+type depEntry struct {
+	Raw string `json:"raw"`
+	// Virtual is true if the entry has a virtual? prefix, meaning that it names a virtual package
+	// to be satisfied by any package providing it.
+	Virtual bool   `json:"virtual,omitempty"`
+	Context string `json:"context"`
+	xbps.Pattern
+}
+
+// invalidDep is an entry of a *depends variable that could not be parsed.
+type invalidDep struct {
+	Package  string `json:"pkgname"`
+	Variable string `json:"variable"`
+	Entry    string `json:"entry"`
+	Error    string `json:"error"`
+}
+
+var errVirtualContext = errors.New("virtual? is only valid in depends")
+
+// parseDepEntry parses a single entry of the named *depends variable.
+func parseDepEntry(variable, dep string) (*depEntry, error) {
+	entry := &depEntry{Raw: dep, Context: contextTarget}
+	if ctx, ok := depContexts[variable]; ok {
+		entry.Context = ctx
+	}
+
+	pattern := dep
+	if strings.HasPrefix(dep, virtualPrefix) {
+		if variable != "depends" {
+			return nil, errVirtualContext
+		}
+		entry.Virtual = true
+		pattern = dep[len(virtualPrefix):]
+	}
+
+	pat, err := xbps.ParsePattern(pattern)
+	if err != nil {
+		var perr *xbps.PatternError
+		if errors.As(err, &perr) {
+			return nil, perr.Err
+		}
+		return nil, err
+	}
+	entry.Pattern = pat
+	return entry, nil
+}
+
+// depPatterns holds the parsed *depends variables of a template and its subpackages.
+type depPatterns struct {
+	deps        map[string][]*depEntry
+	subpackages []*subpackagePatterns
+	invalid     []invalidDep
+}
+
+// subpackagePatterns is a subpackage whose depends are output as structured patterns.
+type subpackagePatterns struct {
+	*subpackage
+	Depends []*depEntry `json:"depends"`
+}
+
+// parsePatterns parses the *depends variables of a template and its subpackages. Entries that
+// cannot be parsed are left out and reported as invalid instead.
+func parsePatterns(pkgname string, data *templateData) *depPatterns {
+	p := &depPatterns{deps: make(map[string][]*depEntry, len(data.deps))}
+	parse := func(pkgname, variable string, deps []string) []*depEntry {
+		entries := make([]*depEntry, 0, len(deps))
+		for _, dep := range deps {
+			entry, err := parseDepEntry(variable, dep)
+			if err != nil {
+				p.invalid = append(p.invalid, invalidDep{pkgname, variable, dep, err.Error()})
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	for _, variable := range data.deps.Keys() {
+		p.deps[variable] = parse(pkgname, variable, data.deps[variable])
+	}
+	for _, sub := range data.subpackages {
+		p.subpackages = append(p.subpackages, &subpackagePatterns{sub, parse(sub.Name, "depends", sub.Depends)})
+	}
+	return p
+}
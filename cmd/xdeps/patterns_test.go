@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.spiff.io/nxtools/xbps"
+)
+
+func TestParseDepEntry(t *testing.T) {
+	succ := []struct {
+		Variable, In string
+		Want         depEntry
+	}{
+		{"makedepends", "libfoo-devel", depEntry{
+			Raw:     "libfoo-devel",
+			Context: contextTarget,
+			Pattern: xbps.Pattern{Kind: xbps.PatternName, Name: "libfoo-devel"},
+		}},
+		{"hostmakedepends", "pkg-config>=0.29", depEntry{
+			Raw:     "pkg-config>=0.29",
+			Context: contextHost,
+			Pattern: xbps.Pattern{
+				Kind:        xbps.PatternDewey,
+				Name:        "pkg-config",
+				Constraints: []xbps.Constraint{{Op: ">=", Version: "0.29"}},
+			},
+		}},
+		{"checkdepends", "python3-pytest", depEntry{
+			Raw:     "python3-pytest",
+			Context: contextHost,
+			Pattern: xbps.Pattern{Kind: xbps.PatternName, Name: "python3-pytest"},
+		}},
+		{"depends", "virtual?cron-daemon", depEntry{
+			Raw:     "virtual?cron-daemon",
+			Virtual: true,
+			Context: contextTarget,
+			Pattern: xbps.Pattern{Kind: xbps.PatternName, Name: "cron-daemon"},
+		}},
+		{"depends", "foo-1.0_1", depEntry{
+			Raw:     "foo-1.0_1",
+			Context: contextTarget,
+			Pattern: xbps.Pattern{Kind: xbps.PatternExact, Name: "foo", Version: "1.0_1"},
+		}},
+		{"depends", "perl-[0-9]*", depEntry{
+			Raw:     "perl-[0-9]*",
+			Context: contextTarget,
+			Pattern: xbps.Pattern{Kind: xbps.PatternGlob, Name: "perl", Version: "[0-9]*"},
+		}},
+	}
+	for _, c := range succ {
+		got, err := parseDepEntry(c.Variable, c.In)
+		if err != nil {
+			t.Errorf("parseDepEntry(%q, %q) error = %v", c.Variable, c.In, err)
+		} else if !reflect.DeepEqual(*got, c.Want) {
+			t.Errorf("parseDepEntry(%q, %q) = %#+v; want %#+v", c.Variable, c.In, *got, c.Want)
+		}
+	}
+
+	fail := []struct {
+		Variable, In string
+		Err          error
+	}{
+		{"makedepends", "virtual?cron-daemon", errVirtualContext},
+		{"depends", "virtual?", xbps.ErrPatternNoName},
+		{"depends", "foo>=", xbps.ErrPatternNoVersion},
+		{"makedepends", "foo*", xbps.ErrPatternBadName},
+	}
+	for _, c := range fail {
+		if _, err := parseDepEntry(c.Variable, c.In); err != c.Err {
+			t.Errorf("parseDepEntry(%q, %q) error = %v; want %v", c.Variable, c.In, err, c.Err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+)
+
+// evaluator evaluates templates, serving results from its cache when possible.
+type evaluator struct {
+	extract func(ctx context.Context, path string, prof *profile) (*templateData, error)
+	// mode names extract in cache keys.
+	mode  string
+	cache *resultCache
+}
+
+// job is a single template to evaluate for a single profile.
+type job struct {
+	index   int
+	file    string
+	profile *profile
+}
+
+// eval evaluates a job.
+func (e *evaluator) eval(j job) depsResult {
+	f, prof := j.file, j.profile
+
+	var key string
+	if e.cache != nil && f != "-" {
+		var err error
+		if key, err = e.cache.key(e.mode, f, prof); err != nil {
+			return depsResult{j.index, f, prof, nil, toEvalError(err), nil}
+		}
+		if cr, ok := e.cache.get(key); ok {
+			return depsResult{j.index, f, prof, cr.templateData(), cr.Err, cr.Diag}
+		}
+	}
+
+	ctx, sb := newSandbox(context.Background(), f)
+	var output *templateOutput
+	if Diagnostics {
+		output = &templateOutput{}
+		ctx = withOutput(ctx, output)
+	}
+	data, err := e.extract(ctx, f, prof)
+	var ee *evalError
+	if err = sb.done(ctx, err); err != nil {
+		data, ee = nil, toEvalError(err)
+	}
+	diag := output.diagnostics()
+	if key != "" && cacheable(ee) {
+		e.cache.put(key, newCachedResult(data, ee, diag))
+	}
+	return depsResult{j.index, f, prof, data, ee, diag}
+}
+
+// run evaluates every input for every profile using a pool of workers, calling emit with each
+// result from the calling goroutine. If ordered is true, results are emitted in input order (and
+// profile order within an input); otherwise they are emitted as soon as they're available.
+//
+// At most 2*workers jobs are in flight or waiting to be emitted at any time, so memory use does
+// not grow with the number of inputs unless emit holds on to results.
+func (e *evaluator) run(inputs []string, profiles []*profile, workers int, ordered bool, emit func(depsResult)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan job)
+	out := make(chan depsResult, workers)
+	// window limits the number of jobs started but not yet emitted. Without it, a single slow
+	// template would let the reorder buffer grow to hold every result after it.
+	window := make(chan struct{}, 2*workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				out <- e.eval(j)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i, f := range inputs {
+			f := filepath.Clean(f)
+			for j, prof := range profiles {
+				window <- struct{}{}
+				jobs <- job{i*len(profiles) + j, f, prof}
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	next, pending := 0, map[int]depsResult{}
+	for r := range out {
+		if !ordered {
+			emit(r)
+			<-window
+			continue
+		}
+		pending[r.index] = r
+		for r, ok := pending[next]; ok; r, ok = pending[next] {
+			delete(pending, next)
+			emit(r)
+			<-window
+			next++
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvaluatorOrdered(t *testing.T) {
+	const (
+		n       = 20
+		workers = 3
+	)
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("srcpkgs/p%02d/template", i)
+	}
+	profiles := []*profile{testProfile(t, "x86_64", "x86_64"), testProfile(t, "x86_64", "aarch64")}
+
+	var (
+		mu                  sync.Mutex
+		inFlight, maxFlight int
+	)
+	e := &evaluator{extract: func(ctx context.Context, path string, prof *profile) (*templateData, error) {
+		mu.Lock()
+		if inFlight++; inFlight > maxFlight {
+			maxFlight = inFlight
+		}
+		mu.Unlock()
+		// Earlier inputs take longer, so that results arrive out of order.
+		var i int
+		fmt.Sscanf(path, "srcpkgs/p%02d/template", &i)
+		time.Sleep(time.Duration(n-i) * 100 * time.Microsecond)
+		if i == 3 {
+			return nil, errors.New("failed")
+		}
+		return &templateData{pkgname: path}, nil
+	}}
+
+	var got []depsResult
+	e.run(inputs, profiles, workers, true, func(r depsResult) {
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		got = append(got, r)
+	})
+
+	if len(got) != n*len(profiles) {
+		t.Fatalf("run emitted %d results; want %d", len(got), n*len(profiles))
+	}
+	for i, r := range got {
+		file, prof := inputs[i/len(profiles)], profiles[i%len(profiles)]
+		if r.index != i || r.file != file || r.profile != prof {
+			t.Errorf("result %d = %d %s (%v); want %d %s (%v)", i, r.index, r.file, r.profile, i, file, prof)
+		}
+		if wantErr := i/len(profiles) == 3; (r.err != nil) != wantErr {
+			t.Errorf("result %d error = %v; want error %t", i, r.err, wantErr)
+		} else if !wantErr && r.data.pkgname != file {
+			t.Errorf("result %d pkgname = %q; want %q", i, r.data.pkgname, file)
+		}
+	}
+	if maxFlight > 2*workers {
+		t.Errorf("run had %d jobs in flight; want at most %d", maxFlight, 2*workers)
+	}
+}
+
+func TestEvaluatorUnordered(t *testing.T) {
+	inputs := []string{"srcpkgs/slow/template", "srcpkgs/a/template", "srcpkgs/b/template"}
+
+	// The first template can't finish until another result has been emitted, which only happens
+	// if results are emitted as soon as they're available.
+	release := make(chan struct{})
+	e := &evaluator{extract: func(ctx context.Context, path string, prof *profile) (*templateData, error) {
+		if path == inputs[0] {
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("timed out waiting for another result")
+			}
+		}
+		return &templateData{pkgname: path}, nil
+	}}
+
+	var (
+		once sync.Once
+		seen = map[string]int{}
+	)
+	e.run(inputs, []*profile{nil}, 2, false, func(r depsResult) {
+		if r.err != nil {
+			t.Errorf("%s error = %v", r.file, r.err)
+		}
+		seen[r.file]++
+		if r.file != inputs[0] {
+			once.Do(func() { close(release) })
+		}
+	})
+
+	for _, f := range inputs {
+		if seen[f] != 1 {
+			t.Errorf("run emitted %s %d times; want 1", f, seen[f])
+		}
+	}
+}
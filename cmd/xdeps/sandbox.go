@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sandboxPolicy limits what evaluating a single template may do.
+type sandboxPolicy struct {
+	// Timeout is the wall-clock time allowed for evaluating a template, including all of its
+	// passes and subpackages. Zero means no limit.
+	Timeout time.Duration
+	// MaxOutput is the number of bytes a template may write to stdout and stderr. Zero means no
+	// limit.
+	MaxOutput int64
+	// ReadPaths are paths, in addition to the template's own directory (which holds its files/
+	// and patches/), that a template may read from.
+	ReadPaths []string
+}
+
+// Sandbox is the policy templates are evaluated under. Commands run on the host with -exec (see
+// AllowExec) are checked before they run, but are not otherwise confined: checkArgs rejects the
+// constructs of permitted execs that could escape the sandbox, such as find -exec, sed w, and awk
+// getline, and any file operands the sandbox does not allow reading.
+var Sandbox = sandboxPolicy{
+	Timeout:   30 * time.Second,
+	MaxOutput: 1 << 20,
+}
+
+// Kinds of sandbox violations.
+const (
+	violationTimeout = "timeout"
+	violationOutput  = "output"
+	violationRead    = "read"
+	violationExec    = "exec"
+)
+
+// sandboxViolation is the error returned for a template that violated the sandbox policy.
+type sandboxViolation struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+func (v *sandboxViolation) Error() string {
+	return fmt.Sprintf("sandbox violation (%s): %s", v.Kind, v.Detail)
+}
+
+// sandbox enforces Sandbox for a single template. The first violation cancels the template's
+// context, stopping the interpreter.
+type sandbox struct {
+	dir    string
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	written   int64
+	violation *sandboxViolation
+}
+
+type sandboxKey struct{}
+
+// newSandbox returns a context for evaluating the template at path under Sandbox, along with the
+// sandbox enforcing it. The sandbox's done method must be called once evaluation is finished.
+func newSandbox(ctx context.Context, path string) (context.Context, *sandbox) {
+	var cancel context.CancelFunc
+	if Sandbox.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, Sandbox.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		dir = filepath.Dir(path)
+	}
+	sb := &sandbox{dir: dir, cancel: cancel}
+	return context.WithValue(ctx, sandboxKey{}, sb), sb
+}
+
+// sandboxFrom returns the sandbox carried by ctx, or nil if there is none.
+func sandboxFrom(ctx context.Context) *sandbox {
+	sb, _ := ctx.Value(sandboxKey{}).(*sandbox)
+	return sb
+}
+
+// violate records a violation, if none has been recorded yet, and stops the template. It returns
+// the first violation recorded.
+func (sb *sandbox) violate(kind, format string, args ...interface{}) error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.violation == nil {
+		sb.violation = &sandboxViolation{kind, fmt.Sprintf(format, args...)}
+		sb.cancel()
+	}
+	return sb.violation
+}
+
+// done releases the sandbox's resources and returns the error evaluating the template should
+// fail with: the first violation, if there was one, or err otherwise.
+func (sb *sandbox) done(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		sb.violate(violationTimeout, "evaluation took longer than %v", Sandbox.Timeout)
+	}
+	sb.cancel()
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.violation != nil {
+		return sb.violation
+	}
+	return err
+}
+
+// resetOutput discards the count of bytes written so far, so that output from an evaluation that
+// is thrown away does not count towards MaxOutput. It has no effect on a nil *sandbox.
+func (sb *sandbox) resetOutput() {
+	if sb == nil {
+		return
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.written = 0
+}
+
+// limitWriter returns a writer that counts bytes written to w towards the sandbox's MaxOutput.
+func (sb *sandbox) limitWriter(w io.Writer) io.Writer {
+	return &sandboxWriter{sb, w}
+}
+
+type sandboxWriter struct {
+	sb *sandbox
+	w  io.Writer
+}
+
+func (w *sandboxWriter) Write(p []byte) (int, error) {
+	sb := w.sb
+	sb.mu.Lock()
+	sb.written += int64(len(p))
+	over := Sandbox.MaxOutput > 0 && sb.written > Sandbox.MaxOutput
+	sb.mu.Unlock()
+	if over {
+		return 0, sb.violate(violationOutput, "wrote more than %d bytes", Sandbox.MaxOutput)
+	}
+	return w.w.Write(p)
+}
+
+// readable returns whether the sandbox allows reading the file at path.
+func (sb *sandbox) readable(path string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(sb.dir, path)
+	}
+	path = resolvePath(path)
+	for _, root := range append([]string{sb.dir}, Sandbox.ReadPaths...) {
+		if root, err := filepath.Abs(root); err == nil && within(resolvePath(root), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath returns the clean, absolute path with symlinks resolved, so that a link cannot
+// point outside of the paths allowed by the sandbox. If path does not exist, symlinks are
+// resolved in the longest leading part of it that does.
+func resolvePath(path string) string {
+	path = filepath.Clean(path)
+	for dir, rest := path, ""; ; {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// within returns whether path is root or is inside it. Both must be clean, absolute paths.
+func within(root, path string) bool {
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// checkArgs checks that a command run on the host does not use any construct that would let it
+// write files, run other commands, or read files other than its operands (see unsafeUsage), and
+// that its file operands (see fileOperands) do not name files the sandbox does not allow reading.
+// Relative paths are resolved against the template's directory, which is where commands are run.
+func (sb *sandbox) checkArgs(cmd string, args []string) error {
+	if what := unsafeUsage(cmd, args); what != "" {
+		return sb.violate(violationExec, "%s: %s", cmd, what)
+	}
+	for _, p := range fileOperands(cmd, args) {
+		if p != "" && p != "-" && !sb.readable(p) {
+			return sb.violate(violationRead, "%s: %s", cmd, p)
+		}
+	}
+	return nil
+}
+
+// operandSpec describes the command line of a permitted exec, so that its file operands can be
+// told apart from its patterns, scripts, and option values.
+type operandSpec struct {
+	argOpts  string // Short options that take an argument.
+	fileOpts string // Short options among argOpts whose argument is a file.
+	// script is true if the first operand is a pattern, script, or program rather than a file,
+	// unless one of scriptOpts is given.
+	script     bool
+	scriptOpts string
+	// assigns is true if operands of the form var=value are assignments, as with awk.
+	assigns bool
+}
+
+var operandSpecs = map[string]operandSpec{
+	"awk":       {argOpts: "fvF", fileOpts: "f", script: true, scriptOpts: "f", assigns: true},
+	"grep":      {argOpts: "efmABCdD", fileOpts: "f", script: true, scriptOpts: "ef"},
+	"egrep":     {argOpts: "efmABCdD", fileOpts: "f", script: true, scriptOpts: "ef"},
+	"fgrep":     {argOpts: "efmABCdD", fileOpts: "f", script: true, scriptOpts: "ef"},
+	"sed":       {argOpts: "efl", fileOpts: "f", script: true, scriptOpts: "ef"},
+	"date":      {argOpts: "drf", fileOpts: "rf"},
+	"sha256sum": {},
+	"sha1sum":   {},
+	"md5sum":    {},
+	"shasum":    {argOpts: "a"},
+}
+
+// longFileOpts are the long options, given as --name=value, whose value is a file.
+var longFileOpts = newStringSet("file", "reference")
+
+// longScriptOpts are the long options, given as --name=value, that supply a pattern or script in
+// place of the first operand.
+var longScriptOpts = newStringSet("file", "regexp", "expression")
+
+// findFileOpts are the find primaries that take a file.
+var findFileOpts = newStringSet("-newer", "-anewer", "-cnewer", "-samefile")
+
+// fileOperands returns the arguments of cmd that name files it may read. Commands without an
+// operandSpec are assumed to read any of their arguments, or the values of their options.
+func fileOperands(cmd string, args []string) []string {
+	if cmd == "find" {
+		return findOperands(args)
+	}
+	spec, ok := operandSpecs[cmd]
+	if !ok {
+		var paths []string
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") {
+				paths = append(paths, arg)
+			} else if i := strings.IndexByte(arg, '='); i != -1 {
+				paths = append(paths, arg[i+1:])
+			} else if len(arg) > 2 && arg[1] != '-' {
+				paths = append(paths, arg[2:])
+			}
+		}
+		return paths
+	}
+
+	return parseCommandLine(cmd, spec, args).files
+}
+
+// commandLine is the command line of a permitted exec split up according to its operandSpec.
+type commandLine struct {
+	// files holds the operands and option values that name files the command may read.
+	files []string
+	// scripts holds the patterns, scripts, and programs given in place of a file.
+	scripts []string
+	// opts holds each short option given, and long the name of each long option given.
+	opts string
+	long []string
+}
+
+func parseCommandLine(cmd string, spec operandSpec, args []string) *commandLine {
+	var (
+		line     = &commandLine{}
+		operands []string
+		scripted bool
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "--"):
+			name := arg[2:]
+			eq := strings.IndexByte(name, '=')
+			if eq != -1 {
+				name = name[:eq]
+			}
+			line.long = append(line.long, name)
+			if eq == -1 {
+				continue
+			}
+			value := arg[2+eq+1:]
+			if longFileOpts.Has(name) {
+				line.files = append(line.files, value)
+			}
+			if longScriptOpts.Has(name) {
+				scripted = true
+				if !longFileOpts.Has(name) {
+					line.scripts = append(line.scripts, value)
+				}
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			for j := 1; j < len(arg); j++ {
+				opt := arg[j]
+				line.opts += string(opt)
+				if cmd == "sed" && opt == 'i' {
+					break // -i takes an optional, attached suffix.
+				}
+				if strings.IndexByte(spec.argOpts, opt) == -1 {
+					continue
+				}
+				value := arg[j+1:]
+				if value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				isFile := strings.IndexByte(spec.fileOpts, opt) != -1
+				if isFile {
+					line.files = append(line.files, value)
+				}
+				if strings.IndexByte(spec.scriptOpts, opt) != -1 {
+					scripted = true
+					if !isFile {
+						line.scripts = append(line.scripts, value)
+					}
+				}
+				break
+			}
+		default:
+			operands = append(operands, arg)
+		}
+	}
+
+	if spec.script && !scripted && len(operands) > 0 {
+		line.scripts = append(line.scripts, operands[0])
+		operands = operands[1:]
+	}
+	for _, op := range operands {
+		if spec.assigns && isAssignment(op) {
+			continue
+		}
+		line.files = append(line.files, op)
+	}
+	return line
+}
+
+// findOperands returns the starting points of a find command and the files named by primaries
+// such as -newer.
+func findOperands(args []string) []string {
+	var paths []string
+	i := 0
+	for ; i < len(args); i++ {
+		if arg := args[i]; strings.HasPrefix(arg, "-") || arg == "(" || arg == "!" {
+			break
+		}
+		paths = append(paths, args[i])
+	}
+	for ; i < len(args); i++ {
+		if findFileOpts.Has(args[i]) && i+1 < len(args) {
+			i++
+			paths = append(paths, args[i])
+		}
+	}
+	return paths
+}
+
+// isAssignment returns whether s is an awk var=value operand.
+func isAssignment(s string) bool {
+	eq := strings.IndexByte(s, '=')
+	if eq <= 0 {
+		return false
+	}
+	for i, c := range s[:eq] {
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// sandboxOpen opens files for the interpreter. Writes are discarded, and so are reads of
+// /dev/null. Other reads are only allowed for paths the sandbox allows reading.
+func sandboxOpen(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	if path == os.DevNull || flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return nopStream(0), nil
+	}
+	sb := sandboxFrom(ctx)
+	if sb == nil {
+		return nopStream(0), nil
+	}
+	if !sb.readable(path) {
+		return nil, sb.violate(violationRead, "%s", path)
+	}
+	return os.Open(path)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileOperands(t *testing.T) {
+	cases := []struct {
+		Cmd  string
+		Args []string
+		Want []string
+	}{
+		{"grep", []string{"/usr/lib", "file"}, []string{"file"}},
+		{"grep", []string{"-q", "-e", "/usr/lib", "file"}, []string{"file"}},
+		{"grep", []string{"-f", "/etc/pats", "file"}, []string{"/etc/pats", "file"}},
+		{"grep", []string{"-if/etc/pats"}, []string{"/etc/pats"}},
+		{"grep", []string{"-m", "1", "--file=pats", "--", "-x"}, []string{"pats", "-x"}},
+		{"sed", []string{"s|/usr|/opt|", "a", "b"}, []string{"a", "b"}},
+		{"sed", []string{"-i.bak", "-e", "s/a/b/", "a"}, []string{"a"}},
+		{"awk", []string{"-F:", "-v", "x=/etc", "{print}", "n=1", "/etc/passwd"}, []string{"/etc/passwd"}},
+		{"awk", []string{"-f", "prog.awk", "input"}, []string{"prog.awk", "input"}},
+		{"find", []string{".", "/opt", "-name", "/etc", "-newer", "stamp"}, []string{".", "/opt", "stamp"}},
+		{"find", []string{"(", "-name", "x", ")"}, nil},
+		{"date", []string{"-d", "/etc", "-r", "file", "+%s"}, []string{"file", "+%s"}},
+		{"shasum", []string{"-a", "256", "file"}, []string{"file"}},
+		{"unknown", []string{"-f/etc/x", "--in=y", "z"}, []string{"/etc/x", "y", "z"}},
+	}
+	for _, c := range cases {
+		if got := fileOperands(c.Cmd, c.Args); !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("fileOperands(%q, %q) = %q; want %q", c.Cmd, c.Args, got, c.Want)
+		}
+	}
+}
+
+func TestUnsafeUsage(t *testing.T) {
+	cases := []struct {
+		Cmd  string
+		Args []string
+		Want string
+	}{
+		{"find", []string{".", "-name", "*.c", "-print"}, ""},
+		{"find", []string{".", "-exec", "cat", "/etc/shadow", ";"}, "-exec"},
+		{"find", []string{".", "-name", "*.o", "-delete"}, "-delete"},
+		{"find", []string{".", "-fprint", "/tmp/out"}, "-fprint"},
+
+		{"sed", []string{"-n", "/^#/d; 1,$p", "file"}, ""},
+		{"sed", []string{"-e", "s/a/b/g", "-e", "y/abc/xyz/", "-e", "$a\\\nend"}, ""},
+		{"sed", []string{"/start/,/end/{ s|/usr|/opt|; }"}, ""},
+		{"sed", []string{"0~2{N;s/\\n/ /}"}, ""},
+		{"sed", []string{"-i", "s/a/b/", "file"}, "in-place editing"},
+		{"sed", []string{"--in-place=.bak", "s/a/b/", "file"}, "in-place editing"},
+		{"sed", []string{"-f", "script.sed", "file"}, "script file"},
+		{"sed", []string{"r /etc/passwd"}, "sed r command"},
+		{"sed", []string{"-e", "/x/w /tmp/out"}, "sed w command"},
+		{"sed", []string{"1e id"}, "sed e command"},
+		{"sed", []string{"s/a/b/w /tmp/out"}, `sed s flag "w"`},
+		{"sed", []string{"s/.*/id/e"}, `sed s flag "e"`},
+		{"sed", []string{"s/a/b/; 2W out"}, "sed W command"},
+		{"sed", []string{"s/a/b"}, `malformed s command in "s/a/b"`},
+		{"sed", []string{"1?"}, `unrecognized sed command "?"`},
+
+		{"awk", []string{"-F:", "{ print $1 > 3 ? $1 : 0 }"}, "awk output redirection"},
+		{"awk", []string{"-F:", "$3 > 1000 && $3 / 2 < 10 { print $1, ($2 > 1) }", "file"}, ""},
+		{"awk", []string{`/a|b/ || $1 ~ "x|y" { printf "%s\n", $0 }`}, ""},
+		{"awk", []string{"# print > x\n{ print $1 }"}, ""},
+		{"awk", []string{`{ getline line < "/etc/passwd"; print line }`}, "awk getline"},
+		{"awk", []string{`BEGIN { system("id") }`}, "awk system"},
+		{"awk", []string{`{ print | "sh" }`}, "awk pipe"},
+		{"awk", []string{`{ print $0 >> "/tmp/out" }`}, "awk output redirection"},
+		{"awk", []string{"-f", "prog.awk", "input"}, "script file"},
+		{"awk", []string{"-i", "inplace", "{ print }", "input"}, "script file"},
+		{"awk", []string{`@load "filefuncs"`}, "awk @ directive"},
+
+		{"grep", []string{"-e", "r /etc/passwd", "file"}, ""},
+	}
+	for _, c := range cases {
+		if got := unsafeUsage(c.Cmd, c.Args); got != c.Want {
+			t.Errorf("unsafeUsage(%q, %q) = %q; want %q", c.Cmd, c.Args, got, c.Want)
+		}
+	}
+}
+
+func TestSandboxExec(t *testing.T) {
+	defer func(old bool) { AllowExec = old }(AllowExec)
+	AllowExec = true
+
+	for _, script := range []string{
+		`find . -exec cat /etc/shadow \;`,
+		`awk 'BEGIN { while ((getline line < "/etc/passwd") > 0) print line }'`,
+		`echo | sed '1r /etc/passwd'`,
+	} {
+		_, err := evalTestTemplate(t, extractDeps, "pkgname=foo\ndepends=\"$("+script+")\"\n")
+		v, ok := err.(*sandboxViolation)
+		if !ok || v.Kind != violationExec {
+			t.Errorf("%s: error = %v; want %s sandbox violation", script, err, violationExec)
+		}
+	}
+}
+
+func TestSandboxReadable(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	outside, cleanup := tempDir(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	sb := &sandbox{dir: dir}
+	cases := []struct {
+		Path string
+		Want bool
+	}{
+		{"file", true},
+		{"missing", true},
+		{filepath.Join(dir, "file"), true},
+		{"../file", false},
+		{"link", false},
+		{"link/file", false},
+		{outside, false},
+	}
+	for _, c := range cases {
+		if got := sb.readable(c.Path); got != c.Want {
+			t.Errorf("readable(%q) = %t; want %t", c.Path, got, c.Want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"mvdan.cc/sh/interp"
 )
@@ -15,7 +17,7 @@ func shVOptIf(ctx context.Context, path string, args []string) error {
 		return err
 	}
 	opt := args[0]
-	if Options.Has(opt) {
+	if optionsFrom(ctx).Has(opt) {
 		if len(args) > 1 {
 			write(ctx, args[1])
 		}
@@ -37,7 +39,7 @@ func shVOptWith(ctx context.Context, path string, args []string) error {
 	if len(args) > 1 {
 		flag = args[1]
 	}
-	if Options.Has(opt) {
+	if optionsFrom(ctx).Has(opt) {
 		with = "with"
 	}
 	write(ctx, "--"+with+"-"+flag)
@@ -54,7 +56,7 @@ func shVOptEnable(ctx context.Context, path string, args []string) error {
 	if len(args) > 1 {
 		flag = args[1]
 	}
-	if Options.Has(opt) {
+	if optionsFrom(ctx).Has(opt) {
 		enable = "enable"
 	}
 	write(ctx, "--"+enable+"-"+flag)
@@ -66,13 +68,22 @@ func shVOptConflict(ctx context.Context, path string, args []string) error {
 	if err := argRangeCheck(cmd, len(args), 2, 2); err != nil {
 		return err
 	}
-	if Options.Has(args[0]) && Options.Has(args[1]) {
-		return fmt.Errorf("%s: cannot set options %s and %s simultaneously",
-			getPackage(ctx), args[0], args[1])
+	if optionsFrom(ctx).Has(args[0]) && optionsFrom(ctx).Has(args[1]) {
+		return &optionConflictError{getPackage(ctx), args[0], args[1]}
 	}
 	return nil
 }
 
+// optionConflictError is returned by vopt_conflict if both of its options are enabled.
+type optionConflictError struct {
+	pkg  string
+	a, b string
+}
+
+func (e *optionConflictError) Error() string {
+	return fmt.Sprintf("%s: cannot set options %s and %s simultaneously", e.pkg, e.a, e.b)
+}
+
 func shVOptBool(ctx context.Context, path string, args []string) error {
 	cmd, args := args[0], args[1:]
 	if err := argRangeCheck(cmd, len(args), 2, 2); err != nil {
@@ -80,17 +91,140 @@ func shVOptBool(ctx context.Context, path string, args []string) error {
 	}
 	opt := args[0]
 	prop, val := args[1], "false"
-	if Options.Has(opt) {
+	if optionsFrom(ctx).Has(opt) {
 		val = "true"
 	}
 	write(ctx, "-D"+prop+"="+val)
 	return nil
 }
 
+func shVOptFeature(ctx context.Context, path string, args []string) error {
+	cmd, args := args[0], args[1:]
+	if err := argRangeCheck(cmd, len(args), 1, 2); err != nil {
+		return err
+	}
+	opt := args[0]
+	prop, val := opt, "disabled"
+	if len(args) > 1 {
+		prop = args[1]
+	}
+	if optionsFrom(ctx).Has(opt) {
+		val = "enabled"
+	}
+	write(ctx, "-D"+prop+"="+val)
+	return nil
+}
+
+// msg_*
+
+// shMsgError records its message and then fails, since msg_error exits xbps-src.
+func shMsgError(ctx context.Context, path string, args []string) error {
+	actionsFrom(ctx).record(ctx, args)
+	return &templateError{getPackage(ctx), strings.TrimSpace(strings.Join(args[1:], " "))}
+}
+
+// templateError is returned by msg_error.
+type templateError struct {
+	pkg string
+	msg string
+}
+
+func (e *templateError) Error() string {
+	return fmt.Sprintf("%s: msg_error: %s", e.pkg, e.msg)
+}
+
+// Inert helpers
+
+// inertHelper returns a function that checks that it was called with min..max arguments (with no
+// maximum if max is negative), passes them to check (if not nil), and then records the call in
+// the context's action log instead of doing anything.
+func inertHelper(min, max int, check func(args []string) error) interp.ModuleExec {
+	return func(ctx context.Context, path string, args []string) error {
+		if err := argRangeCheck(args[0], len(args)-1, min, max); err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(args[1:]); err != nil {
+				return fmt.Errorf("%s: %v", args[0], err)
+			}
+		}
+		actionsFrom(ctx).record(ctx, args)
+		return nil
+	}
+}
+
+// checkMode returns a check that the argument at index i, if present, is an octal file mode.
+func checkMode(i int) func([]string) error {
+	return func(args []string) error {
+		if i >= len(args) {
+			return nil
+		}
+		if _, err := strconv.ParseUint(args[i], 8, 32); err != nil {
+			return fmt.Errorf("invalid file mode: %q", args[i])
+		}
+		return nil
+	}
+}
+
+func checkCompletion(args []string) error {
+	switch args[1] {
+	case "bash", "fish", "zsh":
+		return nil
+	}
+	return fmt.Errorf("unknown shell: %q", args[1])
+}
+
+// checkVSed checks that vsed was called as vsed -i <file> -e <expr> [-e <expr>...].
+func checkVSed(args []string) error {
+	var files, exprs int
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-i", "-e":
+			if i+1 == len(args) {
+				return fmt.Errorf("%s requires an argument", args[i])
+			}
+			if args[i] == "-i" {
+				files++
+			} else {
+				exprs++
+			}
+			i++
+		default:
+			return fmt.Errorf("invalid argument: %q", args[i])
+		}
+	}
+	if files == 0 {
+		return fmt.Errorf("no files given with -i")
+	} else if exprs == 0 {
+		return fmt.Errorf("no expressions given with -e")
+	}
+	return nil
+}
+
 var shFuncs = map[string]interp.ModuleExec{
 	"vopt_if":       shVOptIf,
 	"vopt_with":     shVOptWith,
 	"vopt_enable":   shVOptEnable,
 	"vopt_conflict": shVOptConflict,
 	"vopt_bool":     shVOptBool,
+	"vopt_feature":  shVOptFeature,
+
+	"msg_normal": inertHelper(0, -1, nil),
+	"msg_warn":   inertHelper(0, -1, nil),
+	"msg_red":    inertHelper(0, -1, nil),
+	"msg_error":  shMsgError,
+
+	"vinstall":    inertHelper(3, 4, checkMode(1)),
+	"vcopy":       inertHelper(2, 2, nil),
+	"vmove":       inertHelper(1, 1, nil),
+	"vmkdir":      inertHelper(1, 2, checkMode(1)),
+	"vbin":        inertHelper(1, 2, nil),
+	"vman":        inertHelper(1, 2, nil),
+	"vdoc":        inertHelper(1, 2, nil),
+	"vconf":       inertHelper(1, 2, nil),
+	"vsconf":      inertHelper(1, 2, nil),
+	"vlicense":    inertHelper(1, 2, nil),
+	"vsv":         inertHelper(1, 2, nil),
+	"vcompletion": inertHelper(2, 3, checkCompletion),
+	"vsed":        inertHelper(4, -1, checkVSed),
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInertHelpers(t *testing.T) {
+	cases := []struct {
+		Args []string
+		Err  string
+	}{
+		{[]string{"vinstall", "foo.conf", "644", "etc"}, ""},
+		{[]string{"vinstall", "foo.conf", "644", "etc", "bar.conf"}, ""},
+		{[]string{"vinstall", "foo.conf", "rw-r--r--", "etc"}, `vinstall: invalid file mode: "rw-r--r--"`},
+		{[]string{"vinstall", "foo.conf", "644"}, "vinstall: expected 3..4 arguments, got 2"},
+		{[]string{"vmkdir", "usr/lib/foo"}, ""},
+		{[]string{"vmkdir", "usr/lib/foo", "0755"}, ""},
+		{[]string{"vmkdir", "usr/lib/foo", "999"}, `vmkdir: invalid file mode: "999"`},
+		{[]string{"vmove", "usr/lib/*.so", "extra"}, "vmove: expected 1 arguments, got 2"},
+		{[]string{"msg_normal"}, ""},
+		{[]string{"vcompletion", "foo.bash", "bash"}, ""},
+		{[]string{"vcompletion", "foo.csh", "csh"}, `vcompletion: unknown shell: "csh"`},
+	}
+	for _, c := range cases {
+		log := &actionLog{}
+		err := shFuncs[c.Args[0]](withActions(context.Background(), log), "", c.Args)
+		if c.Err != "" {
+			if err == nil || err.Error() != c.Err {
+				t.Errorf("%q: error = %v; want %q", c.Args, err, c.Err)
+			}
+			if len(log.list()) != 0 {
+				t.Errorf("%q: recorded %v; want nothing recorded on error", c.Args, log.list())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: error = %v", c.Args, err)
+			continue
+		}
+		want := []action{{Func: c.Args[0], Args: c.Args[1:], PkgName: "<no-pkgname>"}}
+		if got := log.list(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: recorded %v; want %v", c.Args, got, want)
+		}
+	}
+}
+
+func TestCheckVSed(t *testing.T) {
+	cases := []struct {
+		Args []string
+		Err  string
+	}{
+		{[]string{"-i", "Makefile", "-e", "s/-Werror//"}, ""},
+		{[]string{"-e", "s/a/b/", "-i", "a.c", "-i", "b.c", "-e", "s/c/d/"}, ""},
+		{[]string{"-i", "Makefile", "s/-Werror//"}, `invalid argument: "s/-Werror//"`},
+		{[]string{"-i", "Makefile", "-e"}, "-e requires an argument"},
+		{[]string{"-e", "s/a/b/", "-e", "s/c/d/"}, "no files given with -i"},
+		{[]string{"-i", "a.c", "-i", "b.c"}, "no expressions given with -e"},
+	}
+	for _, c := range cases {
+		err := checkVSed(c.Args)
+		if c.Err == "" && err != nil {
+			t.Errorf("checkVSed(%q) error = %v", c.Args, err)
+		} else if c.Err != "" && (err == nil || !strings.Contains(err.Error(), c.Err)) {
+			t.Errorf("checkVSed(%q) error = %v; want %q", c.Args, err, c.Err)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "sort"
+import (
+	"sort"
+	"strings"
+)
 
 type stringLists map[string][]string
 
@@ -16,3 +19,19 @@ func (d stringLists) Keys() []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// commaList is a flag.Value that accumulates comma-separated strings.
+type commaList []string
+
+func (l *commaList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
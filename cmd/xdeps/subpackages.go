@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/expand"
+	"mvdan.cc/sh/interp"
+	"mvdan.cc/sh/syntax"
+)
+
+const subpackageSuffix = "_package"
+
+// subpackageVars are variables unset before calling a <name>_package function, as done by
+// xbps-src, so that subpackages do not inherit them from the main package.
+var subpackageVars = []string{
+	"alternatives",
+	"conf_files",
+	"conflicts",
+	"depends",
+	"make_dirs",
+	"mutable_files",
+	"noverifyrdeps",
+	"nostrip",
+	"preserve",
+	"provides",
+	"replaces",
+	"repository",
+	"shlib_provides",
+	"shlib_requires",
+	"system_accounts",
+	"system_groups",
+	"triggers",
+}
+
+// subpackage holds the data extracted from a template's <name>_package function.
+type subpackage struct {
+	Name      string   `json:"pkgname"`
+	Depends   []string `json:"depends"`
+	ShortDesc string   `json:"short_desc,omitempty"`
+	// PkgInstall is true if the function defines a pkg_install function.
+	PkgInstall bool `json:"pkg_install"`
+}
+
+// subpackageNames returns the names of a template's subpackages. As with xbps-src, these are taken
+// from the subpackages variable if it is set, or from the names of all <name>_package functions
+// otherwise.
+func subpackageNames(runner *interp.Runner) []string {
+	if vr := runner.Vars["subpackages"]; vr.IsSet() {
+		return strings.Fields(vr.String())
+	}
+
+	var names []string
+	for fn := range runner.Funcs {
+		if name := strings.TrimSuffix(fn, subpackageSuffix); name != fn && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extractSubpackages calls each subpackage function of a template that runner has run and returns
+// the data each one sets. Each function is called in isolation: the interpreter's variables and
+// functions are restored after each call.
+func extractSubpackages(ctx context.Context, runner *interp.Runner) ([]*subpackage, error) {
+	names := subpackageNames(runner)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	sourcepkg := runner.Vars["pkgname"].String()
+	subs := make([]*subpackage, 0, len(names))
+	for _, name := range names {
+		sub, err := callSubpackage(ctx, runner, name, sourcepkg)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func callSubpackage(ctx context.Context, runner *interp.Runner, name, sourcepkg string) (*subpackage, error) {
+	vars := make(map[string]expand.Variable, len(runner.Vars))
+	for k, v := range runner.Vars {
+		vars[k] = v
+	}
+	funcs := make(map[string]*syntax.Stmt, len(runner.Funcs))
+	for k, v := range runner.Funcs {
+		funcs[k] = v
+	}
+	defer func() {
+		runner.Vars, runner.Funcs = vars, funcs
+	}()
+
+	for _, k := range subpackageVars {
+		delete(runner.Vars, k)
+	}
+	delete(runner.Funcs, "pkg_install")
+	runner.Vars["pkgname"] = expand.Variable{Value: name}
+	runner.Vars["sourcepkg"] = expand.Variable{Value: sourcepkg}
+
+	sub := &subpackage{Name: name, Depends: []string{}}
+	if runner.Funcs[name+subpackageSuffix] == nil {
+		// Listed in subpackages but never defined -- report it without calling anything.
+		return sub, nil
+	}
+
+	call := &syntax.CallExpr{Args: []*syntax.Word{{
+		Parts: []syntax.WordPart{&syntax.Lit{Value: name + subpackageSuffix}},
+	}}}
+	// A function ending in a false test still succeeds (see ignoreExitStatus).
+	if err := ignoreExitStatus(runner.Run(ctx, call)); err != nil {
+		return nil, err
+	}
+
+	sub.Depends = append(sub.Depends, strings.Fields(runner.Vars["depends"].String())...)
+	sub.ShortDesc = runner.Vars["short_desc"].String()
+	sub.PkgInstall = runner.Funcs["pkg_install"] != nil
+	return sub, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// evalTestTemplate evaluates a template with the given contents using extract, in a sandbox and
+// without a profile, and returns its data.
+func evalTestTemplate(t *testing.T, extract func(context.Context, string, *profile) (*templateData, error), template string) (*templateData, error) {
+	t.Helper()
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := writeTestFile(t, dir, "srcpkgs/foo/template", template)
+	ctx, sb := newSandbox(context.Background(), path)
+	data, err := extract(withOutput(ctx, &templateOutput{}), path, nil)
+	return data, sb.done(ctx, err)
+}
+
+func TestExtractSubpackages(t *testing.T) {
+	const template = `pkgname=foo
+version=1.0
+revision=2
+depends="libc"
+short_desc="Foo"
+conflicts="bar"
+pkg_install() { :; }
+%s
+foo-devel_package() {
+	depends="${sourcepkg}>=${version}_${revision}"
+	short_desc+=" - development files"
+	pkg_install() { vmove usr/include; }
+}
+foo-doc_package() {
+	short_desc+=" - documentation"
+	[ "$CROSS_BUILD" ] && depends="cross"
+}
+`
+	devel := &subpackage{Name: "foo-devel", Depends: []string{"foo>=1.0_2"}, ShortDesc: "Foo - development files", PkgInstall: true}
+	doc := &subpackage{Name: "foo-doc", Depends: []string{}, ShortDesc: "Foo - documentation"}
+	cases := []struct {
+		Subpackages string
+		Want        []*subpackage
+	}{
+		// Without subpackages, every *_package function is called, in name order.
+		{"", []*subpackage{devel, doc}},
+		// Otherwise, only those listed, in order, including any that aren't defined.
+		{`subpackages="foo-doc foo-missing"`, []*subpackage{doc, {Name: "foo-missing", Depends: []string{}}}},
+	}
+	for _, c := range cases {
+		data, err := evalTestTemplate(t, extractDeps, fmt.Sprintf(template, c.Subpackages))
+		if err != nil {
+			t.Errorf("%q: extractDeps() error = %v", c.Subpackages, err)
+			continue
+		}
+		if !reflect.DeepEqual(data.subpackages, c.Want) {
+			t.Errorf("%q: subpackages = %+v; want %+v", c.Subpackages, data.subpackages, c.Want)
+		}
+		// Subpackage functions don't change the main package's variables.
+		if want := []string{"libc"}; !reflect.DeepEqual(data.deps["depends"], want) {
+			t.Errorf("%q: depends = %q; want %q", c.Subpackages, data.deps["depends"], want)
+		}
+	}
+}
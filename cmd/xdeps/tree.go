@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// srcpkgTree describes the srcpkgs directory of a void-packages checkout. Each source package is a
+// directory holding a template, and each subpackage is a symlink to its source package's
+// directory.
+type srcpkgTree struct {
+	root string
+	// sources holds the names of all source packages, sorted.
+	sources []string
+	// links maps source package names to the names of subpackage symlinks pointing to them.
+	links map[string][]string
+	// sourceOf maps source package and subpackage names to source package names.
+	sourceOf map[string]string
+}
+
+// scanTree scans the srcpkgs directory of the void-packages checkout at root.
+func scanTree(root string) (*srcpkgTree, error) {
+	dir := filepath.Join(root, "srcpkgs")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &srcpkgTree{
+		root:     root,
+		links:    map[string][]string{},
+		sourceOf: map[string]string{},
+	}
+
+	var symlinks []os.FileInfo
+	for _, fi := range entries {
+		name := fi.Name()
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			symlinks = append(symlinks, fi)
+		case fi.IsDir():
+			if _, err := os.Stat(filepath.Join(dir, name, "template")); err != nil {
+				continue
+			}
+			t.sources = append(t.sources, name)
+			t.sourceOf[name] = name
+		}
+	}
+
+	for _, fi := range symlinks {
+		name := fi.Name()
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		source := filepath.Base(filepath.Clean(target))
+		if t.sourceOf[source] != source {
+			return nil, fmt.Errorf("%s: subpackage symlink does not point to a source package: %s",
+				filepath.Join(dir, name), target)
+		}
+		t.links[source] = append(t.links[source], name)
+		t.sourceOf[name] = source
+	}
+
+	sort.Strings(t.sources)
+	for _, links := range t.links {
+		sort.Strings(links)
+	}
+	return t, nil
+}
+
+// templatePath returns the path of a source package's template.
+func (t *srcpkgTree) templatePath(source string) string {
+	return filepath.Join(t.root, "srcpkgs", source, "template")
+}
+
+// templates returns the template paths of the named packages' source packages, in the order
+// given, without duplicates. Packages may be named by their source package or any subpackage. If
+// no packages are named, the templates of all source packages are returned.
+func (t *srcpkgTree) templates(names ...string) ([]string, error) {
+	if len(names) == 0 {
+		names = t.sources
+	}
+
+	paths := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		source, ok := t.sourceOf[name]
+		if !ok {
+			return nil, fmt.Errorf("no such package in %s: %s", t.root, name)
+		}
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+		paths = append(paths, t.templatePath(source))
+	}
+	return paths, nil
+}
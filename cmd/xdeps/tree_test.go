@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanTree(t *testing.T) {
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	writeTestFile(t, root, "srcpkgs/foo/template", "pkgname=foo\n")
+	writeTestFile(t, root, "srcpkgs/bar/template", "pkgname=bar\n")
+	writeTestFile(t, root, "srcpkgs/baz/files/README", "not a template\n")
+	for _, link := range []string{"libfoo", "foo-devel"} {
+		if err := os.Symlink("foo", filepath.Join(root, "srcpkgs", link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tree, err := scanTree(root)
+	if err != nil {
+		t.Fatalf("scanTree() error = %v", err)
+	}
+	if want := []string{"bar", "foo"}; !reflect.DeepEqual(tree.sources, want) {
+		t.Errorf("sources = %q; want %q", tree.sources, want)
+	}
+	if want := []string{"foo-devel", "libfoo"}; !reflect.DeepEqual(tree.links["foo"], want) {
+		t.Errorf("links[foo] = %q; want %q", tree.links["foo"], want)
+	}
+
+	paths, err := tree.templates("libfoo", "bar", "foo")
+	if err != nil {
+		t.Fatalf("templates() error = %v", err)
+	}
+	want := []string{tree.templatePath("foo"), tree.templatePath("bar")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("templates(libfoo, bar, foo) = %q; want %q", paths, want)
+	}
+
+	if paths, _ = tree.templates(); len(paths) != 2 {
+		t.Errorf("templates() = %q; want all source packages", paths)
+	}
+	if _, err := tree.templates("baz"); err == nil {
+		t.Error("templates(baz): expected error for a directory without a template")
+	}
+}
+
+func TestScanTreeBadLink(t *testing.T) {
+	root, cleanup := tempDir(t)
+	defer cleanup()
+	writeTestFile(t, root, "srcpkgs/foo/template", "pkgname=foo\n")
+	if err := os.Symlink("missing", filepath.Join(root, "srcpkgs", "libmissing")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := scanTree(root)
+	if err == nil || !strings.Contains(err.Error(), "does not point to a source package") {
+		t.Errorf("scanTree() error = %v; want bad symlink error", err)
+	}
+}
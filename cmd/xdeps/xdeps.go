@@ -4,93 +4,300 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
+	"go.spiff.io/nxtools/depgraph"
+	"go.spiff.io/nxtools/xrepo"
 	"mvdan.cc/sh/expand"
 	"mvdan.cc/sh/interp"
 	"mvdan.cc/sh/syntax"
 )
 
 type depsResult struct {
-	index int
-	file  string
-	deps  stringLists
-	err   error
+	index   int
+	file    string
+	profile *profile
+	data    *templateData
+	err     *evalError
+	// diag is the template's captured output, if Diagnostics is set.
+	diag *diagnostics
 }
 
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("ERR ") // All stderr output is errors
 
-	flag.Var(Options, "o", "")
+	var (
+		host     = flag.String("host", "x86_64", "host machine (XBPS_MACHINE) used with -a, and the target machine with -common if no -a is given")
+		treeDir  = flag.String("tree", "", "void-packages checkout whose srcpkgs are evaluated; arguments name packages to evaluate (default all)")
+		common   = flag.String("common", "", "void-packages common directory sourced around templates (default common in -tree, if any)")
+		mode     = flag.String("mode", "deps", "output mode: deps, meta (deps and template fields), matrix (deps for each build_options combination), order (build order of all templates), graph (dependency graph of all templates), check (compare templates with -repodata), or depcheck (compare depends with -repodata run_depends and shlib-requires)")
+		format   = flag.String("format", formatJSON, "output format of records: json, csv, make (Makefile variables), or sh (shell variables); formats other than json only include depends")
+		graphFmt = flag.String("graph-format", "dot", "graph mode output format: "+strings.Join(depgraph.Formats, ", "))
+		targets  commaList
+		roots    commaList
+		repodata commaList
+	)
+	flag.Var(OptionFlags, "o", "build options to enable (opt) or disable (~opt), comma-separated; each only applies to templates declaring it")
+	flag.Var(&targets, "a", "target machines (XBPS_TARGET_MACHINE) to evaluate templates for")
+	flag.Var(&repodata, "repodata", "in check and depcheck modes, [repo=]path repodata files to compare templates with (for the same arch as -a)")
+	flag.Var(&roots, "root", "in graph mode, only include packages reachable from these packages")
+	flag.BoolVar(&AllowExec, "exec", AllowExec, "run permitted commands (awk, find, and unsupported uses of sed, grep, etc.) on the host; uses that could write files, run commands, or read outside the sandbox are rejected")
+	flag.BoolVar(&Patterns, "patterns", Patterns, "output depends entries as structured patterns (name, version constraints, virtual, host or target)")
+	flag.BoolVar(&Diagnostics, "diag", Diagnostics, "capture the stdout and stderr of each template into its record")
+	flag.DurationVar(&Sandbox.Timeout, "timeout", Sandbox.Timeout, "time allowed for evaluating each template (0 for no limit)")
+	flag.Int64Var(&Sandbox.MaxOutput, "max-output", Sandbox.MaxOutput, "bytes each template may write to stdout and stderr (0 for no limit)")
+	flag.Var((*commaList)(&Sandbox.ReadPaths), "allow-read", "paths templates may read from, in addition to their own directories")
+	var (
+		jobs       = flag.Int("j", runtime.NumCPU(), "number of templates to evaluate concurrently")
+		unordered  = flag.Bool("unordered", false, "output records as soon as templates are evaluated instead of in input order")
+		cacheDir   = flag.String("cache", "", "directory to cache evaluation results in (default no cache)")
+		cacheClear = flag.Bool("cache-clear", false, "remove all cached results before evaluating templates")
+		cachePrune = flag.Bool("cache-prune", false, "remove cached results for older versions of the templates evaluated, in the same mode and configuration")
+		cacheStats = flag.Bool("cache-stats", false, "log cache hits, misses, and stores after evaluating templates")
+	)
+	flag.IntVar(&MatrixMax, "matrix-max", MatrixMax, "maximum build option combinations evaluated per template in matrix mode")
 	flag.Parse()
 
+	var extract func(ctx context.Context, path string, prof *profile) (*templateData, error)
+	// evalMode names the extract function for cache keys, so that modes sharing it share results.
+	evalMode := *mode
+	switch *mode {
+	case "deps":
+		extract = extractDeps
+	case "meta":
+		extract = extractMeta
+	case "matrix":
+		extract = extractMatrix
+	case "order":
+		// Only depends are needed to order templates, so those with invalid meta fields still
+		// take part.
+		extract, evalMode = extractDeps, "deps"
+		if len(targets) > 1 {
+			log.Fatalf("%s mode takes at most one target machine (-a)", *mode)
+		}
+	case "graph":
+		extract, evalMode = extractDeps, "deps"
+		if len(targets) > 1 {
+			log.Fatalf("%s mode takes at most one target machine (-a)", *mode)
+		}
+		// Check the format before evaluating anything.
+		if err := depgraph.New().Export(ioutil.Discard, *graphFmt); err != nil {
+			log.Fatal(err)
+		}
+	case "check", "depcheck":
+		extract, evalMode = extractMeta, "meta"
+		if len(repodata) == 0 {
+			log.Fatalf("%s mode requires -repodata", *mode)
+		}
+	default:
+		log.Fatalf("invalid mode: %q", *mode)
+	}
+
+	records, err := newRecordWriter(os.Stdout, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rd *xrepo.RepoData
+	if len(repodata) > 0 {
+		var err error
+		if rd, err = xrepo.LoadRepos(repodata...); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// A nil profile evaluates templates without any XBPS_* variables set.
+	profiles := []*profile{nil}
+	if len(targets) > 0 {
+		var err error
+		if profiles, err = parseProfiles(*host, targets); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	inputs := flag.Args()
-	results := make([]depsResult, len(inputs))
-
-	out := make(chan depsResult)
-	// Use a simple semaphore to avoid loading too many files / starting too many goroutines.
-	// TODO: better management of nofiles / processes.
-	sema := make(chan struct{}, runtime.NumCPU()*16)
-
-	for i, f := range inputs {
-		i, f := i, filepath.Clean(f)
-		go func() {
-			sema <- struct{}{}
-			defer func() { <-sema }()
-			deps, err := extractDeps(f)
-			out <- depsResult{i, f, deps, err}
-		}()
-	}
-
-	// Read results back
-	for range inputs {
-		r := <-out
-		if r.err != nil {
-			log.Fatalf("%s: %v", r.file, r.err)
-		}
-		results[r.index] = r
-	}
-
-	// Print results as json (so I can look at the data with jq)
-	var buf bytes.Buffer
-	type raw string
-	enc := json.NewEncoder(&buf)
-
-	push := func(args ...interface{}) {
-		for _, arg := range args {
-			switch arg := arg.(type) {
-			case raw:
-				buf.WriteString(string(arg))
-			case rune:
-				buf.WriteRune(arg)
-			default:
-				if err := enc.Encode(arg); err != nil {
-					log.Fatalf("could not encode %T(%v): %v", arg, arg, err)
-				}
-				if sz := buf.Len() - 1; sz >= 0 {
-					buf.Truncate(sz)
-				}
+	var tree *srcpkgTree
+	if *treeDir != "" {
+		var err error
+		if tree, err = scanTree(*treeDir); err != nil {
+			log.Fatal(err)
+		}
+		if inputs, err = tree.templates(inputs...); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *common == "" && tree != nil {
+		if fi, err := os.Stat(filepath.Join(tree.root, "common")); err == nil && fi.IsDir() {
+			*common = filepath.Join(tree.root, "common")
+		}
+	}
+	if *common != "" {
+		var err error
+		if Common, err = loadCommon(*common); err != nil {
+			log.Fatal(err)
+		}
+		// Setup scripts expect the machine variables xbps-src always sets, so build natively
+		// for the host if no target machines were given.
+		if len(targets) == 0 {
+			if profiles, err = parseProfiles(*host, []string{*host}); err != nil {
+				log.Fatal(err)
 			}
 		}
 	}
 
-	for _, r := range results {
-		push('{', "file", ':', r.file)
-		for _, k := range r.deps.Keys() {
-			push(',', k, ':', r.deps[k])
+	var cache *resultCache
+	if *cacheDir != "" {
+		var err error
+		if cache, err = openCache(*cacheDir); err != nil {
+			log.Fatal(err)
+		}
+		if *cacheClear {
+			if err := cache.clear(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	// Templates that fail to evaluate are reported in their own records (or logged, in modes
+	// describing all templates at once) and reflected in the exit status.
+	var (
+		summary   errorSummary
+		results   []depsResult // Only kept in check and depcheck modes, which annotate records.
+		evaluated []depsResult // Only kept in modes describing all templates at once.
+		stream    = true
+	)
+	switch *mode {
+	case "check", "depcheck", "graph", "order":
+		stream = false
+	}
+
+	// Formats other than json can't describe failed evaluations, so those are logged instead.
+	// Results are counted in the summary once written, since check mode may fail them.
+	writeResult := func(r depsResult) {
+		summary.add(r.err)
+		if r.err != nil && *format != formatJSON {
+			logFailure(&r)
+		}
+		if err := records.write(newRecord(&r, tree)); err != nil {
+			log.Fatal(err)
 		}
-		push(raw("}\n"))
-		buf.WriteTo(os.Stdout)
 	}
+
+	// Options given with -o only apply to templates that declare them, but an option no template
+	// declares is most likely a mistake.
+	declared, anyEvaluated := optionSet{}, false
+
+	e := &evaluator{extract: extract, mode: evalMode, cache: cache}
+	e.run(inputs, profiles, *jobs, !*unordered, func(r depsResult) {
+		if r.data != nil {
+			anyEvaluated = true
+			for _, opt := range r.data.options {
+				declared.Add(opt.Name)
+			}
+		}
+		switch {
+		case stream:
+			writeResult(r)
+			return
+		case *mode == "check" || *mode == "depcheck":
+			results = append(results, r)
+		default:
+			summary.add(r.err)
+			if r.err != nil {
+				logFailure(&r)
+			}
+		}
+		if r.err == nil {
+			evaluated = append(evaluated, r)
+		}
+	})
+
+	badOptions := false
+	if err := undeclaredOptions(OptionFlags, declared); err != nil && anyEvaluated {
+		log.Print(err)
+		badOptions = true
+	}
+
+	if cache != nil {
+		if *cachePrune {
+			if err := cache.prune(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *cacheStats {
+			fmt.Fprintf(os.Stderr, "cache: %v\n", cache.stats)
+		}
+	}
+
+	switch *mode {
+	case "check":
+		checkResults(results, tree, rd)
+	case "depcheck":
+		depCheckResults(evaluated, rd)
+	case "graph":
+		if err := graphResults(os.Stdout, *graphFmt, evaluated, tree, roots); err != nil {
+			log.Fatal(err)
+		}
+	case "order":
+		order := orderResults(evaluated, tree)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(order); err != nil {
+			log.Fatal(err)
+		}
+		for _, cycle := range order.Cycles {
+			log.Printf("build dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+		exitSummary(&summary, badOptions || len(order.Cycles) > 0)
+		return
+	}
+
+	for _, r := range results {
+		writeResult(r)
+	}
+
+	exitSummary(&summary, badOptions)
+}
+
+// logFailure logs the error of a failed result.
+func logFailure(r *depsResult) {
+	if r.profile != nil {
+		log.Printf("%s (%v): %s: %v", r.file, r.profile, r.err.Kind, r.err)
+	} else {
+		log.Printf("%s: %s: %v", r.file, r.err.Kind, r.err)
+	}
+}
+
+// exitSummary logs the summary, if any evaluation failed, and exits with the status returned by
+// exitStatus if it is not 0.
+func exitSummary(summary *errorSummary, failed bool) {
+	if summary.count() > 0 {
+		log.Print(summary)
+	}
+	if status := exitStatus(summary, failed); status != 0 {
+		os.Exit(status)
+	}
+}
+
+// exitStatus returns 1 if any evaluation failed or if failed is true, and 0 otherwise.
+func exitStatus(summary *errorSummary, failed bool) int {
+	if failed || summary.count() > 0 {
+		return 1
+	}
+	return 0
 }
 
 func parseFile(path string) (*syntax.File, error) {
@@ -103,27 +310,80 @@ func parseFile(path string) (*syntax.File, error) {
 		defer f.Close()
 		r = f
 	}
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 
 	parser := syntax.NewParser(syntax.Variant(syntax.LangBash))
-	file, err := parser.Parse(r, path)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %v", path, err)
+	file, err := parser.Parse(bytes.NewReader(src), path)
+	if perr, ok := err.(syntax.ParseError); ok {
+		kind := errorParse
+		if parsedToEOF(src, path) {
+			kind = errorIncomplete
+		}
+		return nil, &evalError{Kind: kind, Message: perr.Pos.String() + ": " + perr.Text}
 	} else if parser.Incomplete() {
-		return nil, fmt.Errorf("%s: incomplete template", path)
+		return nil, &evalError{Kind: errorIncomplete, Message: "incomplete template"}
+	} else if err != nil {
+		return nil, err
 	}
 	return file, nil
 }
 
+// parsedToEOF parses src, which must not be valid, and returns whether the parser ran out of
+// input before failing. This tells a template that ends in the middle of a statement apart from
+// one with a syntax error, since errors such as an if without a fi are reported at the start of
+// the statement rather than at the end of the input.
+func parsedToEOF(src []byte, path string) bool {
+	r := &eofReader{r: bytes.NewReader(src)}
+	syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(r, path)
+	return r.eof
+}
+
+// eofReader reads one byte at a time, so that it only reaches the end of its input if its reader
+// needs all of it.
+type eofReader struct {
+	r   io.Reader
+	eof bool
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// argRangeCheck returns an error if n is not in the range min..max. If max is negative, there is
+// no maximum.
 func argRangeCheck(name string, n, min, max int) error {
-	if n >= min && n <= max {
+	if n >= min && (max < 0 || n <= max) {
 		return nil
 	}
-	if min == max {
+	if max < 0 {
+		return fmt.Errorf("%s: expected at least %d arguments, got %d", name, min, n)
+	} else if min == max {
 		return fmt.Errorf("%s: expected %d arguments, got %d", name, min, n)
 	}
 	return fmt.Errorf("%s: expected %d..%d arguments, got %d", name, min, max, n)
 }
 
+// ignoreExitStatus returns err unless it is an interp.ExitStatus. As when xbps-src sources a
+// template, a non-zero status left by its last command (such as a false test in
+// `[ "$CROSS_BUILD" ] && hostmakedepends=qemu`) is not an error.
+func ignoreExitStatus(err error) error {
+	var status interp.ExitStatus
+	if errors.As(err, &status) {
+		return nil
+	}
+	return err
+}
+
 // write writes a string to a module context's stdout (linked in ctx).
 func write(ctx context.Context, s string) error {
 	mod, ok := interp.FromModuleContext(ctx)
@@ -161,18 +421,25 @@ var permittedExecs = newStringSet(
 	"shasum",
 )
 
-func nopOpen(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
-	return nopStream(0), nil
-}
-
 func limitedExec(ctx context.Context, path string, args []string) error {
 	cmd := args[0]
 	if fn := shFuncs[cmd]; fn != nil {
 		return fn(ctx, path, args)
 	}
 
+	if fn := builtinExecs[cmd]; fn != nil {
+		err := fn(ctx, path, args)
+		if _, ok := err.(errUnsupported); !ok {
+			return err
+		} else if !AllowExec {
+			return execDenied(cmd, "%v (use -exec to run it on the host)", err)
+		}
+	}
+
 	if !permittedExecs.Has(cmd) {
-		return fmt.Errorf("unrecognized function or permitted exec: %q", cmd)
+		return execDenied(cmd, "unrecognized function or permitted exec")
+	} else if !AllowExec {
+		return execDenied(cmd, "no builtin implementation (use -exec to run it on the host)")
 	}
 
 	mod, ok := interp.FromModuleContext(ctx)
@@ -182,15 +449,19 @@ func limitedExec(ctx context.Context, path string, args []string) error {
 
 	pkgname := getPackage(ctx)
 
-	cmdexec := exec.Command(cmd, args[1:]...)
-	cmdexec.Stdin, cmdexec.Stdout, cmdexec.Stderr = mod.Stdin, mod.Stdout, newPrefixWriter(mod.Stderr, pkgname+": ")
-	var env []string
-	mod.Env.Each(func(name string, vr expand.Variable) bool {
-		if s, ok := vr.Value.(string); ok {
-			env = append(env, name+"="+s)
+	cmdexec := exec.CommandContext(ctx, cmd, args[1:]...)
+	if sb := sandboxFrom(ctx); sb != nil {
+		if err := sb.checkArgs(cmd, args[1:]); err != nil {
+			return err
 		}
-		return true
-	})
+		cmdexec.Dir = mod.Dir
+	}
+	cmdexec.Stdin, cmdexec.Stdout, cmdexec.Stderr = mod.Stdin, mod.Stdout, mod.Stderr
+	if out := outputFrom(ctx); out == nil || out.terminal {
+		// Output from concurrent templates is interleaved, so mark whose it is.
+		cmdexec.Stderr = newPrefixWriter(mod.Stderr, pkgname+": ")
+	}
+	cmdexec.Env = exportedEnv(mod.Env)
 
 	err := cmdexec.Run()
 	if err != nil {
@@ -199,33 +470,113 @@ func limitedExec(ctx context.Context, path string, args []string) error {
 	return nil
 }
 
-func extractDeps(path string) (deps stringLists, err error) {
+// templateData holds the data extracted from a template.
+type templateData struct {
+	pkgname     string
+	deps        stringLists
+	options     []buildOption
+	subpackages []*subpackage
+	meta        *templateMeta
+	matrix      *optionMatrix
+	check       *repoCheck
+	depcheck    []*depCheck
+	actions     []action
+}
+
+// extractDeps evaluates the template at path and returns its *depends variables, build options,
+// and subpackages. If prof is not nil, the template is evaluated with the XBPS_* variables of that
+// profile set.
+func extractDeps(ctx context.Context, path string, prof *profile) (*templateData, error) {
+	data, _, err := evalTemplate(ctx, path, prof)
+	return data, err
+}
+
+// evalTemplate evaluates the template at path as described by extractDeps, and returns both the
+// extracted data and the interpreter the template was evaluated in.
+//
+// Like xbps-src, the template is evaluated twice: once to read its build_options and
+// build_options_default, and again with the resulting options (and any given with -o) enabled.
+func evalTemplate(ctx context.Context, path string, prof *profile) (*templateData, *interp.Runner, error) {
 	file, err := parseFile(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// TODO: capture all IO and do something with it?
-	// var (
-	// 	stdin  = &bytes.Buffer{}
-	// 	stdout = &bytes.Buffer{}
-	// 	stderr = &bytes.Buffer{}
-	// )
+	env := templateEnv(prof)
 
-	runner, err := interp.New(interp.StdIO(nopStream(0), os.Stdout, os.Stderr))
+	// Only the calls made and output written by the final evaluation are kept, so the first
+	// evaluation's output is held back until it's known whether it is the final one.
+	actions, final := &actionLog{}, true
+	first := &templateOutput{terminal: outputFrom(ctx) == nil || outputFrom(ctx).terminal}
+	defer func() {
+		if final {
+			first.copyTo(outputFrom(ctx))
+		}
+	}()
+	runner, err := runTemplate(withOutput(withActions(ctx, actions), first), file, env)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	runner.Exec = limitedExec
-	runner.Open = nopOpen
+	opts, buildOpts := templateOptions(func(name string) string {
+		return runner.Vars[name].String()
+	}, OptionFlags)
+
+	if len(buildOpts) > 0 {
+		actions, final = &actionLog{}, false
+		sandboxFrom(ctx).resetOutput()
+		if runner, err = runWithOptions(withActions(ctx, actions), file, env, opts); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data := &templateData{
+		pkgname: runner.Vars["pkgname"].String(),
+		deps:    collectDeps(runner),
+		options: buildOpts,
+	}
+	if data.subpackages, err = extractSubpackages(withActions(withOptions(ctx, opts), actions), runner); err != nil {
+		return nil, nil, err
+	}
+	data.actions = actions.list()
+	return data, runner, nil
+}
+
+// exportedEnv returns the exported variables of env as name=value pairs, sorted by name. The
+// result is never nil, so that commands given it do not inherit the host environment.
+func exportedEnv(env expand.Environ) []string {
+	vars := map[string]expand.Variable{}
+	env.Each(func(name string, vr expand.Variable) bool {
+		vars[name] = vr
+		return true
+	})
+
+	list := make([]string, 0, len(vars))
+	for name, vr := range vars {
+		if vr.Exported && vr.IsSet() {
+			list = append(list, name+"="+vr.String())
+		}
+	}
+	sort.Strings(list)
+	return list
+}
 
-	ctx := context.Background()
-	if err = runner.Run(ctx, file); err != nil {
-		return nil, fmt.Errorf("%s: %v", path, err)
+// templateEnv returns the environment templates are evaluated in: the variables of Common, if set,
+// and of prof, if not nil.
+func templateEnv(prof *profile) []string {
+	var env []string
+	if Common != nil {
+		env = Common.environ()
+	}
+	if prof != nil {
+		env = append(env, prof.environ()...)
 	}
+	return env
+}
 
-	deps = stringLists{}
+// collectDeps returns the *depends variables of an interpreter that has run a template.
+func collectDeps(runner *interp.Runner) stringLists {
+	deps := stringLists{}
 
 	for name, vr := range runner.Vars {
 		if !strings.HasSuffix(name, "depends") {
@@ -242,5 +593,59 @@ func extractDeps(path string) (deps stringLists, err error) {
 		}
 	}
 
-	return deps, nil
+	return deps
+}
+
+// runWithOptions runs a parsed template with the given build options enabled. As in xbps-src,
+// build_option_<name>=1 is set in the environment for each enabled option.
+func runWithOptions(ctx context.Context, file *syntax.File, env []string, opts optionSet) (*interp.Runner, error) {
+	env = append([]string(nil), env...)
+	for opt := range opts {
+		env = append(env, "build_option_"+opt+"=1")
+	}
+	return runTemplate(withOptions(ctx, opts), file, env)
+}
+
+// runTemplate runs a parsed template in a new interpreter with the given environment and returns
+// the interpreter. If Common is set, its setup scripts are sourced before the template and the
+// template's build style and helper environments after it.
+func runTemplate(ctx context.Context, file *syntax.File, env []string) (*interp.Runner, error) {
+	var (
+		stdout io.Writer = os.Stdout
+		stderr io.Writer = os.Stderr
+		dir    string
+	)
+	if out := outputFrom(ctx); out != nil {
+		stdout, stderr = out.writers()
+	}
+	if sb := sandboxFrom(ctx); sb != nil {
+		stdout, stderr, dir = sb.limitWriter(stdout), sb.limitWriter(stderr), sb.dir
+	}
+
+	runner, err := interp.New(
+		interp.Env(expand.ListEnviron(env...)),
+		interp.StdIO(nopStream(0), stdout, stderr),
+		interp.Dir(dir),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	runner.Exec = limitedExec
+	runner.Open = sandboxOpen
+
+	if Common != nil {
+		if err = Common.runSetup(ctx, runner); err != nil {
+			return nil, err
+		}
+	}
+	if err = ignoreExitStatus(runner.Run(ctx, file)); err != nil {
+		return nil, err
+	}
+	if Common != nil {
+		if err = Common.runEnvironment(ctx, runner); err != nil {
+			return nil, err
+		}
+	}
+	return runner, nil
 }
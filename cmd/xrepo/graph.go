@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.spiff.io/nxtools/depgraph"
+)
+
+func cmdGraph(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("graph", flag.ExitOnError)
+		format = fs.String("f", "dot", "output format ("+strings.Join(depgraph.Formats, ", ")+")")
+		kinds  stringList
+		roots  stringList
+	)
+	fs.Var(&kinds, "k", "dependency kinds to include (run, shlib; default all)")
+	fs.Var(&roots, "r", "only include packages reachable from these packages")
+	fs.Parse(args)
+
+	rd, err := loadRepoData(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var ks []depgraph.Kind
+	for _, k := range kinds {
+		switch kind := depgraph.Kind(k); kind {
+		case depgraph.Run, depgraph.Shlib:
+			ks = append(ks, kind)
+		default:
+			return fmt.Errorf("graph: invalid dependency kind: %q", k)
+		}
+	}
+
+	g := rd.Graph(ks...)
+	if len(roots) > 0 {
+		for _, r := range roots {
+			if !g.HasNode(r) {
+				return fmt.Errorf("graph: no such package: %s", r)
+			}
+		}
+		g = g.Subgraph(roots...)
+	}
+	return g.Export(os.Stdout, *format)
+}
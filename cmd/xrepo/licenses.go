@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.spiff.io/nxtools/license"
+	"go.spiff.io/nxtools/xrepo"
+)
+
+func cmdLicenses(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("licenses", flag.ExitOnError)
+		format = fs.String("f", "text", "output format (text or json)")
+		deny   stringList
+		roots  stringList
+	)
+	fs.Var(&deny, "d", "license classes to deny (default copyleft,nonfree,unknown)")
+	fs.Var(&roots, "p", "root packages whose dependencies are checked (default all packages)")
+	fs.Parse(args)
+
+	if len(deny) == 0 {
+		deny = stringList{"copyleft", "nonfree", "unknown"}
+	}
+
+	var policy xrepo.LicensePolicy
+	for _, name := range deny {
+		c, err := license.ParseClass(name)
+		if err != nil {
+			return err
+		}
+		policy.Deny = append(policy.Deny, c)
+	}
+
+	rd, err := loadRepoData(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	for _, name := range roots {
+		if rd.Package(name) == nil {
+			return fmt.Errorf("no such package: %s", name)
+		}
+	}
+
+	violations := rd.CheckLicenses(policy, roots...)
+	switch *format {
+	case "text":
+		for _, v := range violations {
+			classes := make([]string, len(v.Classes))
+			for i, c := range v.Classes {
+				classes[i] = c.String()
+			}
+			fmt.Printf("%s %s (%s): %s\n",
+				strings.Join(classes, ","), v.Package, v.License, strings.Join(v.Path, " -> "))
+		}
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		for _, v := range violations {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("invalid output format: %q", *format)
+	}
+
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"go.spiff.io/nxtools/xrepo"
+)
+
+func cmdLint(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("lint", flag.ExitOnError)
+		format = fs.String("f", "text", "output format (text or json)")
+		minSev = fs.String("s", "info", "minimum severity to report (info, warning, or error)")
+		names  stringList
+	)
+	fs.Var(&names, "c", "lint checks to run (default all)")
+	fs.Parse(args)
+
+	sev, err := xrepo.ParseSeverity(*minSev)
+	if err != nil {
+		return err
+	}
+
+	checks, err := xrepo.LintChecks(names...)
+	if err != nil {
+		return err
+	}
+
+	rd, err := loadRepoData(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	report := xrepo.Lint(rd, checks...).Filter(sev)
+	switch *format {
+	case "text":
+		err = report.WriteText(os.Stdout)
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	default:
+		return fmt.Errorf("invalid output format: %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Count(xrepo.SeverityError) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
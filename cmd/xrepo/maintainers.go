@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.spiff.io/nxtools/xrepo"
+)
+
+func cmdMaintainers(args []string) error {
+	var (
+		fs      = flag.NewFlagSet("maintainers", flag.ExitOnError)
+		format  = fs.String("f", "text", "output format (text or json)")
+		orphans = fs.Bool("o", false, "list orphaned packages instead of maintainers")
+	)
+	fs.Parse(args)
+
+	rd, err := loadRepoData(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if *orphans {
+		return writeOrphans(*format, rd.Index().Orphaned())
+	}
+
+	stats := rd.Index().MaintainerStats()
+	switch *format {
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "PACKAGES\tINSTALLED\tOLDEST BUILD\tOLDEST PACKAGE\tMAINTAINER")
+		for _, s := range stats {
+			fmt.Fprintf(w, "%d\t%v\t%s\t%s\t%s\n",
+				s.Packages,
+				xrepo.Size(s.InstalledSize),
+				s.OldestBuild.Time().Format(time.RFC3339),
+				s.OldestPackage,
+				s.Maintainer,
+			)
+		}
+		return w.Flush()
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		for _, s := range stats {
+			if err := enc.Encode(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid output format: %q", *format)
+}
+
+func writeOrphans(format string, ps xrepo.Packages) error {
+	return writeResults(format, len(ps), func(i int) interface{} { return ps[i] },
+		func(i int) string { return ps[i].PackageVersion })
+}
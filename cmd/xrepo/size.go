@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func cmdSize(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("size", flag.ExitOnError)
+		format = fs.String("f", "text", "output format (text or json)")
+		pkgs   stringList
+		base   stringList
+	)
+	fs.Var(&pkgs, "p", "packages to size, including their dependencies")
+	fs.Var(&base, "b", "already-installed packages whose dependencies are excluded")
+	fs.Parse(args)
+
+	if len(pkgs) == 0 {
+		return fmt.Errorf("size: no packages given")
+	}
+
+	rd, err := loadRepoData(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	report, err := rd.MarginalSize(pkgs, base)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "INSTALLED\tDOWNLOAD\tREPOSITORY\tPACKAGE")
+		for _, p := range report.Packages {
+			name := p.Package
+			if p.Dependency {
+				name = "  " + name
+			}
+			fmt.Fprintf(w, "%v\t%v\t%s\t%s\n", p.Installed, p.Download, p.Repository, name)
+		}
+		for _, r := range report.Repositories {
+			fmt.Fprintf(w, "%v\t%v\t%s\t(%d packages)\n", r.Installed, r.Download, r.Repository, r.Packages)
+		}
+		fmt.Fprintf(w, "%v\t%v\t\t(%d packages total)\n", report.Installed, report.Download, len(report.Packages))
+		return w.Flush()
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(report)
+	}
+	return fmt.Errorf("invalid output format: %q", *format)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func cmdStale(args []string) error {
+	var (
+		fs        = flag.NewFlagSet("stale", flag.ExitOnError)
+		format    = fs.String("f", "text", "output format (text or json)")
+		before    = fs.String("b", "", "list packages built before this date (YYYY-MM-DD or RFC3339)")
+		drift     = fs.Bool("d", false, "list packages that drift from their sibling subpackages")
+		tolerance = fs.Duration("t", 24*time.Hour, "build-date drift tolerance")
+	)
+	fs.Parse(args)
+
+	if (*before == "") == !*drift {
+		return fmt.Errorf("stale: exactly one of -b or -d is required")
+	}
+
+	rd, err := loadRepoData(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if *drift {
+		results := rd.Index().Drift(*tolerance)
+		return writeResults(*format, len(results), func(i int) interface{} { return results[i] },
+			func(i int) string {
+				d := results[i]
+				return fmt.Sprintf("%s %s: %s is %s; want %s", d.Source, d.Package, d.Field, d.Value, d.Want)
+			})
+	}
+
+	t, err := parseDate(*before)
+	if err != nil {
+		return err
+	}
+	ps := rd.Index().BuiltBefore(t)
+	return writeResults(*format, len(ps), func(i int) interface{} { return ps[i] },
+		func(i int) string {
+			return ps[i].PackageVersion + " " + ps[i].BuildDate.Time().Format(time.RFC3339)
+		})
+}
+
+func cmdSources(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("sources", flag.ExitOnError)
+		format = fs.String("f", "text", "output format (text or json)")
+	)
+	fs.Parse(args)
+
+	rd, err := loadRepoData(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	groups := rd.Index().BySource()
+	return writeResults(*format, len(groups), func(i int) interface{} { return groups[i] },
+		func(i int) string {
+			g := groups[i]
+			return fmt.Sprintf("%s [%s]: %s", g.Source, strings.Join(g.Commits, ","), strings.Join(g.Names, " "))
+		})
+}
+
+// writeResults writes n results to standard output, either as JSON values (one per line) or as
+// lines of text.
+func writeResults(format string, n int, value func(int) interface{}, text func(int) string) error {
+	switch format {
+	case "text":
+		for i := 0; i < n; i++ {
+			fmt.Println(text(i))
+		}
+		return nil
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		for i := 0; i < n; i++ {
+			if err := enc.Encode(value(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid output format: %q", format)
+}
+
+// parseDate parses a date given as either YYYY-MM-DD (in UTC) or RFC3339.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.UTC); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"go.spiff.io/nxtools/xrepo"
+)
+
+// command is an xrepo subcommand. Run receives the subcommand's arguments, excluding its name.
+type command struct {
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"lint":        {"lint [-f text|json] [-s severity] [-c check,...] repodata...", cmdLint},
+	"licenses":    {"licenses [-f text|json] [-d class,...] [-p pkg,...] repodata...", cmdLicenses},
+	"maintainers": {"maintainers [-f text|json] [-o] repodata...", cmdMaintainers},
+	"stale":       {"stale [-f text|json] (-b date | -d [-t tolerance]) repodata...", cmdStale},
+	"sources":     {"sources [-f text|json] repodata...", cmdSources},
+	"size":        {"size [-f text|json] [-b pkg,...] -p pkg,... repodata...", cmdSize},
+	"graph":       {"graph [-f dot|graphml|json] [-k kind,...] [-r pkg,...] repodata...", cmdGraph},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ERR ") // All stderr output is errors
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		log.Printf("unrecognized command: %q", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.Run(flag.Args()[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  xrepo %s\n", commands[name].Usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nRepodata arguments take the form [repo=]path.")
+}
+
+// loadRepoData loads all repodata files given as [repo=]path arguments into a single RepoData.
+func loadRepoData(args []string) (*xrepo.RepoData, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no repodata given")
+	}
+	return xrepo.LoadRepos(args...)
+}
+
+// stringList is a flag.Value accumulating comma-separated strings.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			*s = append(*s, e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package depgraph
+
+import "sort"
+
+// Components returns the strongly connected components of the graph that contain a cycle: that
+// is, every component with more than one node, as well as single nodes with an edge to
+// themselves. Each component is sorted by name, and components are sorted by their first node.
+func (g *Graph) Components() [][]string {
+	t := tarjan{
+		g:     g,
+		index: map[string]int{},
+		low:   map[string]int{},
+		on:    map[string]bool{},
+	}
+	for _, n := range g.Nodes() {
+		if _, ok := t.index[n]; !ok {
+			t.connect(n)
+		}
+	}
+
+	var comps [][]string
+	for _, c := range t.comps {
+		if len(c) == 1 && !g.hasSuccessor(c[0], c[0]) {
+			continue
+		}
+		sort.Strings(c)
+		comps = append(comps, c)
+	}
+	sort.Slice(comps, func(i, j int) bool { return comps[i][0] < comps[j][0] })
+	return comps
+}
+
+// Cycles returns one cycle path per strongly connected component returned by Components. Each
+// path begins and ends with the first node (by name) of its component, and is the shortest such
+// cycle through that node.
+func (g *Graph) Cycles() [][]string {
+	comps := g.Components()
+	cycles := make([][]string, 0, len(comps))
+	for _, c := range comps {
+		members := make(map[string]bool, len(c))
+		for _, n := range c {
+			members[n] = true
+		}
+		cycles = append(cycles, g.cycleThrough(c[0], members))
+	}
+	return cycles
+}
+
+// cycleThrough finds the shortest path from start back to itself, only passing through nodes in
+// members.
+func (g *Graph) cycleThrough(start string, members map[string]bool) []string {
+	prev := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, s := range g.successors(n) {
+			if !members[s] {
+				continue
+			}
+			if s == start {
+				path := []string{start}
+				for ; n != start; n = prev[n] {
+					path = append(path, n)
+				}
+				path = append(path, start)
+				reverse(path)
+				return path
+			}
+			if _, ok := prev[s]; ok {
+				continue
+			}
+			prev[s] = n
+			queue = append(queue, s)
+		}
+	}
+	return nil
+}
+
+func (g *Graph) hasSuccessor(from, to string) bool {
+	succ := g.succ[from]
+	i := sort.SearchStrings(succ, to)
+	return i < len(succ) && succ[i] == to
+}
+
+func reverse(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// tarjan holds the state of Tarjan's strongly connected components algorithm.
+type tarjan struct {
+	g     *Graph
+	next  int
+	index map[string]int
+	low   map[string]int
+	on    map[string]bool
+	stack []string
+	comps [][]string
+}
+
+func (t *tarjan) connect(n string) {
+	t.index[n] = t.next
+	t.low[n] = t.next
+	t.next++
+	t.stack = append(t.stack, n)
+	t.on[n] = true
+
+	for _, s := range t.g.successors(n) {
+		if _, ok := t.index[s]; !ok {
+			t.connect(s)
+			if t.low[s] < t.low[n] {
+				t.low[n] = t.low[s]
+			}
+		} else if t.on[s] && t.index[s] < t.low[n] {
+			t.low[n] = t.index[s]
+		}
+	}
+
+	if t.low[n] != t.index[n] {
+		return
+	}
+
+	var comp []string
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.on[top] = false
+		comp = append(comp, top)
+		if top == n {
+			break
+		}
+	}
+	t.comps = append(t.comps, comp)
+}
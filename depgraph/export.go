@@ -0,0 +1,142 @@
+package depgraph
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Formats lists the formats supported by Export.
+var Formats = []string{"dot", "graphml", "json"}
+
+// ErrFormat is returned by Export when given an unsupported format.
+var ErrFormat = errors.New("unsupported graph format")
+
+// Subgraph returns a new graph containing only the given roots and the nodes reachable from them,
+// along with all edges between those nodes. Roots that are not in the graph are ignored.
+func (g *Graph) Subgraph(roots ...string) *Graph {
+	paths := g.ShortestPaths(roots...)
+	g2 := New()
+	for _, n := range paths.Reachable() {
+		g2.AddNode(n)
+		for _, e := range g.out[n] {
+			g2.AddEdge(e.From, e.To, e.Kind)
+		}
+	}
+	return g2
+}
+
+// Export writes the graph to w in the named format (one of Formats).
+func (g *Graph) Export(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return g.WriteDOT(w)
+	case "graphml":
+		return g.WriteGraphML(w)
+	case "json":
+		return g.WriteJSON(w)
+	}
+	return fmt.Errorf("%w: %q", ErrFormat, format)
+}
+
+// WriteDOT writes the graph to w as a Graphviz digraph. Edges are labeled with their kind.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("digraph deps {\n")
+	for _, n := range g.Nodes() {
+		ew.printf("\t%s;\n", strconv.Quote(n))
+	}
+	for _, e := range g.Edges() {
+		ew.printf("\t%s -> %s [label=%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(string(e.Kind)))
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID string `xml:"id,attr"`
+}
+
+type graphMLEdge struct {
+	Source string      `xml:"source,attr"`
+	Target string      `xml:"target,attr"`
+	Data   graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph to w as a GraphML document. Node IDs are node names, and each
+// edge's kind is stored in its "kind" data attribute.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  []graphMLKey{{ID: "kind", For: "edge", Name: "kind", Type: "string"}},
+		Graph: graphMLGraph{ID: "deps", EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{n})
+	}
+	for _, e := range g.Edges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{e.From, e.To, graphMLData{"kind", string(e.Kind)}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteJSON writes the graph to w as a single JSON object holding its sorted nodes and edges.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(struct {
+		Nodes []string `json:"nodes"`
+		Edges []Edge   `json:"edges"`
+	}{g.Nodes(), g.Edges()})
+}
+
+// errWriter is an io.Writer wrapper that stops writing after its first error.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errWriter) printf(format string, args ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, args...)
+	}
+}
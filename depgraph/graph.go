@@ -0,0 +1,131 @@
+// Package depgraph implements a small directed dependency graph used to describe relationships
+// between packages, both from repodata and from templates.
+package depgraph
+
+import "sort"
+
+// Kind identifies the kind of dependency an edge represents.
+type Kind string
+
+// Edge kinds.
+const (
+	HostMake Kind = "hostmake"
+	Make     Kind = "make"
+	Check    Kind = "check"
+	Run      Kind = "run"
+	Shlib    Kind = "shlib"
+)
+
+// Edge is a directed edge from a dependent node to one of its dependencies.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind Kind   `json:"kind"`
+}
+
+// Graph is a directed graph of named nodes. Edges are deduplicated by their endpoints and kind.
+type Graph struct {
+	nodes map[string]struct{}
+	edges map[Edge]struct{}
+	// out and succ hold each node's edges and distinct successors, kept sorted as edges are
+	// added so that walks over the graph don't sort on every visit.
+	out  map[string][]Edge
+	succ map[string][]string
+}
+
+// New allocates a new, empty Graph.
+func New() *Graph {
+	return &Graph{
+		nodes: map[string]struct{}{},
+		edges: map[Edge]struct{}{},
+		out:   map[string][]Edge{},
+		succ:  map[string][]string{},
+	}
+}
+
+// AddNode adds a node to the graph. Adding an existing node has no effect.
+func (g *Graph) AddNode(name string) {
+	g.nodes[name] = struct{}{}
+}
+
+// AddEdge adds an edge of the given kind from one node to another, adding both nodes if
+// necessary.
+func (g *Graph) AddEdge(from, to string, kind Kind) {
+	g.AddNode(from)
+	g.AddNode(to)
+	e := Edge{from, to, kind}
+	if _, ok := g.edges[e]; ok {
+		return
+	}
+	g.edges[e] = struct{}{}
+
+	out := g.out[from]
+	i := sort.Search(len(out), func(i int) bool { return !edgeLess(out[i], e) })
+	out = append(out, Edge{})
+	copy(out[i+1:], out[i:])
+	out[i] = e
+	g.out[from] = out
+
+	succ := g.succ[from]
+	if i := sort.SearchStrings(succ, to); i == len(succ) || succ[i] != to {
+		succ = append(succ, "")
+		copy(succ[i+1:], succ[i:])
+		succ[i] = to
+		g.succ[from] = succ
+	}
+}
+
+// HasNode returns whether the graph contains the named node.
+func (g *Graph) HasNode(name string) bool {
+	_, ok := g.nodes[name]
+	return ok
+}
+
+// Len returns the number of nodes in the graph.
+func (g *Graph) Len() int {
+	return len(g.nodes)
+}
+
+// Nodes returns all nodes in the graph, sorted by name.
+func (g *Graph) Nodes() []string {
+	nodes := make([]string, 0, len(g.nodes))
+	for n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Edges returns all edges in the graph, sorted by their endpoints and kind.
+func (g *Graph) Edges() []Edge {
+	edges := make([]Edge, 0, len(g.edges))
+	for e := range g.edges {
+		edges = append(edges, e)
+	}
+	sortEdges(edges)
+	return edges
+}
+
+// Out returns the edges leading out of the named node, sorted by their endpoints and kind.
+func (g *Graph) Out(name string) []Edge {
+	return append([]Edge(nil), g.out[name]...)
+}
+
+// successors returns the distinct nodes the named node has edges to, sorted by name. The caller
+// must not modify the returned slice.
+func (g *Graph) successors(name string) []string {
+	return g.succ[name]
+}
+
+func edgeLess(a, b Edge) bool {
+	if a.From != b.From {
+		return a.From < b.From
+	} else if a.To != b.To {
+		return a.To < b.To
+	}
+	return a.Kind < b.Kind
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool { return edgeLess(edges[i], edges[j]) })
+}
@@ -0,0 +1,164 @@
+package depgraph
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testGraph() *Graph {
+	g := New()
+	g.AddEdge("app", "lib", Make)
+	g.AddEdge("app", "tool", HostMake)
+	g.AddEdge("lib", "libc", Run)
+	g.AddEdge("tool", "libc", Run)
+	g.AddEdge("a", "b", Make)
+	g.AddEdge("b", "c", Make)
+	g.AddEdge("c", "a", Run)
+	g.AddEdge("d", "a", Make)
+	g.AddEdge("self", "self", Run)
+	return g
+}
+
+func TestOut(t *testing.T) {
+	g := New()
+	g.AddEdge("a", "c", Run)
+	g.AddEdge("a", "b", Run)
+	g.AddEdge("a", "c", Make)
+	g.AddEdge("a", "b", Run)
+	want := []Edge{{"a", "b", Run}, {"a", "c", Make}, {"a", "c", Run}}
+	if got := g.Out("a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Out(a) = %v; want %v", got, want)
+	}
+	if got := g.successors("a"); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("successors(a) = %q; want [b c]", got)
+	}
+	if !g.hasSuccessor("a", "c") || g.hasSuccessor("a", "d") {
+		t.Error("hasSuccessor(a, c) = false or hasSuccessor(a, d) = true")
+	}
+}
+
+func TestCycles(t *testing.T) {
+	want := [][]string{
+		{"a", "b", "c", "a"},
+		{"self", "self"},
+	}
+	if got := testGraph().Cycles(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Cycles() = %q; want %q", got, want)
+	}
+
+	if got := testGraph().Filter(Make, HostMake).Cycles(); len(got) != 0 {
+		t.Fatalf("Filter(Make, HostMake).Cycles() = %q; want none", got)
+	}
+}
+
+func TestTopoSort(t *testing.T) {
+	order, cycles := testGraph().TopoSort()
+	if want := []string{"libc", "lib", "tool", "app"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("TopoSort() order = %q; want %q", order, want)
+	}
+	if len(cycles) != 2 {
+		t.Fatalf("TopoSort() cycles = %q; want 2 cycles", cycles)
+	}
+}
+
+func TestShortestPaths(t *testing.T) {
+	paths := testGraph().ShortestPaths("app", "d")
+	cases := map[string][]string{
+		"app":  {"app"},
+		"libc": {"app", "lib", "libc"},
+		"c":    {"d", "a", "b", "c"},
+		"self": nil,
+	}
+	for node, want := range cases {
+		if got := paths.To(node); !reflect.DeepEqual(got, want) {
+			t.Errorf("To(%q) = %q; want %q", node, got, want)
+		}
+	}
+
+	want := []string{"a", "app", "b", "c", "d", "lib", "libc", "tool"}
+	if got := paths.Reachable(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reachable() = %q; want %q", got, want)
+	}
+}
+
+func TestPathsTo(t *testing.T) {
+	g := New()
+	g.AddEdge("a", "b", Run)
+	g.AddEdge("a", "c", Run)
+	g.AddEdge("b", "d", Run)
+	g.AddEdge("c", "d", Run)
+	g.AddEdge("d", "e", Run)
+	g.AddEdge("x", "e", Run)
+	g.AddEdge("e", "a", Run)
+
+	cases := []struct {
+		Roots []string
+		To    string
+		Want  []string
+	}{
+		{[]string{"a"}, "a", []string{"a"}},
+		// Ties are broken by visiting successors in name order.
+		{[]string{"a"}, "d", []string{"a", "b", "d"}},
+		{[]string{"a"}, "e", []string{"a", "b", "d", "e"}},
+		{[]string{"a"}, "x", nil},
+		// The path starts at the nearest root, and roots are never reached through another node.
+		{[]string{"a", "x"}, "e", []string{"x", "e"}},
+		{[]string{"x", "a"}, "a", []string{"a"}},
+		{[]string{"x"}, "d", []string{"x", "e", "a", "b", "d"}},
+		{[]string{"missing"}, "missing", nil},
+		{nil, "a", nil},
+	}
+	for _, c := range cases {
+		if got := g.ShortestPaths(c.Roots...).To(c.To); !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("ShortestPaths(%q).To(%q) = %q; want %q", c.Roots, c.To, got, c.Want)
+		}
+	}
+}
+
+func TestSubgraph(t *testing.T) {
+	g := testGraph().Subgraph("app")
+	if want := []string{"app", "lib", "libc", "tool"}; !reflect.DeepEqual(g.Nodes(), want) {
+		t.Fatalf("Subgraph(app).Nodes() = %q; want %q", g.Nodes(), want)
+	}
+	if n := len(g.Edges()); n != 4 {
+		t.Fatalf("Subgraph(app).Edges() = %v; want 4 edges", g.Edges())
+	}
+}
+
+func TestExport(t *testing.T) {
+	g := New()
+	g.AddEdge("app", "lib", Make)
+	g.AddEdge("lib", "libc", Shlib)
+
+	cases := map[string]string{
+		"dot": "digraph deps {\n" +
+			"\t\"app\";\n\t\"lib\";\n\t\"libc\";\n" +
+			"\t\"app\" -> \"lib\" [label=\"make\"];\n" +
+			"\t\"lib\" -> \"libc\" [label=\"shlib\"];\n" +
+			"}\n",
+		"json": `{"nodes":["app","lib","libc"],"edges":[` +
+			`{"from":"app","to":"lib","kind":"make"},` +
+			`{"from":"lib","to":"libc","kind":"shlib"}]}` + "\n",
+	}
+	for format, want := range cases {
+		var buf strings.Builder
+		if err := g.Export(&buf, format); err != nil {
+			t.Errorf("Export(%q) error = %v", format, err)
+		} else if got := buf.String(); got != want {
+			t.Errorf("Export(%q) =\n%s\nwant\n%s", format, got, want)
+		}
+	}
+
+	var buf strings.Builder
+	if err := g.Export(&buf, "graphml"); err != nil {
+		t.Errorf("Export(graphml) error = %v", err)
+	} else if !strings.Contains(buf.String(), `<edge source="lib" target="libc">`) {
+		t.Errorf("Export(graphml) missing lib -> libc edge:\n%s", buf.String())
+	}
+
+	if err := g.Export(&buf, "svg"); !errors.Is(err, ErrFormat) {
+		t.Errorf("Export(svg) error = %v; want ErrFormat", err)
+	}
+}
@@ -0,0 +1,70 @@
+package depgraph
+
+import "sort"
+
+// Filter returns a new graph containing all nodes of the receiver but only edges of the given
+// kinds.
+func (g *Graph) Filter(kinds ...Kind) *Graph {
+	want := map[Kind]bool{}
+	for _, k := range kinds {
+		want[k] = true
+	}
+
+	g2 := New()
+	for n := range g.nodes {
+		g2.AddNode(n)
+	}
+	for _, e := range g.Edges() {
+		if want[e.Kind] {
+			g2.AddEdge(e.From, e.To, e.Kind)
+		}
+	}
+	return g2
+}
+
+// TopoSort returns the nodes of the graph ordered so that every node comes after the nodes it has
+// edges to (i.e., dependencies come before their dependents). Nodes that are ready at the same
+// time are ordered by name.
+//
+// Nodes that are part of a cycle, or that depend on a node that is, cannot be ordered and are left
+// out of the order. If any nodes are left out, the cycles responsible are returned as described by
+// Cycles.
+func (g *Graph) TopoSort() (order []string, cycles [][]string) {
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for n := range g.nodes {
+		succ := g.successors(n)
+		for _, s := range succ {
+			if s != n {
+				dependents[s] = append(dependents[s], n)
+			}
+		}
+		pending[n] = len(succ)
+	}
+
+	var ready []string
+	for n, count := range pending {
+		if count == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		next := ready
+		ready = nil
+		for _, n := range next {
+			order = append(order, n)
+			for _, d := range dependents[n] {
+				if pending[d]--; pending[d] == 0 {
+					ready = append(ready, d)
+				}
+			}
+		}
+	}
+
+	if len(order) < len(g.nodes) {
+		cycles = g.Cycles()
+	}
+	return order, cycles
+}
@@ -0,0 +1,82 @@
+package depgraph
+
+import "sort"
+
+// Paths holds the shortest paths from a set of root nodes to every node reachable from them.
+type Paths struct {
+	roots map[string]bool
+	prev  map[string]string
+}
+
+// ShortestPaths walks the graph breadth-first from the given roots and returns the shortest path
+// to every node reachable from them. Roots that are not in the graph are ignored.
+func (g *Graph) ShortestPaths(roots ...string) *Paths {
+	p := &Paths{
+		roots: map[string]bool{},
+		prev:  map[string]string{},
+	}
+
+	sorted := append([]string(nil), roots...)
+	sort.Strings(sorted)
+
+	queue := make([]string, 0, len(sorted))
+	for _, r := range sorted {
+		if !g.HasNode(r) || p.roots[r] {
+			continue
+		}
+		p.roots[r] = true
+		queue = append(queue, r)
+	}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, s := range g.successors(n) {
+			if _, ok := p.prev[s]; ok || p.roots[s] {
+				continue
+			}
+			p.prev[s] = n
+			queue = append(queue, s)
+		}
+	}
+
+	return p
+}
+
+// Has returns whether the named node is a root or is reachable from a root.
+func (p *Paths) Has(name string) bool {
+	if p.roots[name] {
+		return true
+	}
+	_, ok := p.prev[name]
+	return ok
+}
+
+// Reachable returns all roots and nodes reachable from them, sorted by name.
+func (p *Paths) Reachable() []string {
+	nodes := make([]string, 0, len(p.roots)+len(p.prev))
+	for n := range p.roots {
+		nodes = append(nodes, n)
+	}
+	for n := range p.prev {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// To returns the shortest path from a root to the named node, beginning with the root and ending
+// with the node. If the node is a root, the path contains only the node. If the node is not
+// reachable, To returns nil.
+func (p *Paths) To(name string) []string {
+	if !p.Has(name) {
+		return nil
+	}
+	path := []string{name}
+	for n := name; !p.roots[n]; {
+		n = p.prev[n]
+		path = append(path, n)
+	}
+	reverse(path)
+	return path
+}
@@ -1,5 +1,7 @@
 module go.spiff.io/nxtools
 
+go 1.13
+
 require (
 	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4 // indirect
 	golang.org/x/tools v0.0.0-20190330180304-aef51cc3777c
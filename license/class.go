@@ -0,0 +1,177 @@
+package license
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Class is a broad classification of a license.
+type Class int
+
+// License classes.
+const (
+	// Unknown licenses are not recognized, including most custom: licenses.
+	Unknown Class = iota
+	// Permissive licenses place few conditions on redistribution (e.g., MIT, BSD, Apache).
+	Permissive
+	// WeakCopyleft licenses require sharing changes to the licensed work only (e.g., LGPL, MPL).
+	WeakCopyleft
+	// Copyleft licenses require derived works to use the same license (e.g., GPL, AGPL).
+	Copyleft
+	// NonFree licenses restrict use, modification, or redistribution.
+	NonFree
+)
+
+var classNames = [...]string{
+	Unknown:      "unknown",
+	Permissive:   "permissive",
+	WeakCopyleft: "weak-copyleft",
+	Copyleft:     "copyleft",
+	NonFree:      "nonfree",
+}
+
+// ParseClass parses the name of a license class.
+func ParseClass(s string) (Class, error) {
+	for c, name := range classNames {
+		if strings.EqualFold(s, name) {
+			return Class(c), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid license class: %q", s)
+}
+
+func (c Class) String() string {
+	if c < 0 || int(c) >= len(classNames) {
+		return fmt.Sprintf("Class(%d)", int(c))
+	}
+	return classNames[c]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Class) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Substrings of license names (compared in lower case) that mark a license as non-free.
+var nonFreeWords = []string{
+	"proprietary",
+	"nonfree",
+	"non-free",
+	"commercial",
+	"eula",
+	"cc-by-nc",
+	"cc-by-nd",
+}
+
+// Prefixes of license names (compared in lower case) and their classes. Longer prefixes must
+// come before shorter prefixes they share a beginning with.
+var classPrefixes = []struct {
+	prefix string
+	class  Class
+}{
+	{"agpl", Copyleft},
+	{"gpl", Copyleft},
+	{"cc-by-sa", Copyleft},
+	{"gfdl", Copyleft},
+	{"eupl", Copyleft},
+	{"osl", Copyleft},
+	{"sleepycat", Copyleft},
+
+	{"lgpl", WeakCopyleft},
+	{"mpl", WeakCopyleft},
+	{"epl", WeakCopyleft},
+	{"cddl", WeakCopyleft},
+	{"cpl", WeakCopyleft},
+	{"lppl", WeakCopyleft},
+
+	{"0bsd", Permissive},
+	{"apache", Permissive},
+	{"artistic-2", Permissive},
+	{"bsd", Permissive},
+	{"bsl", Permissive},
+	{"cc-by", Permissive},
+	{"cc0", Permissive},
+	{"curl", Permissive},
+	{"hpnd", Permissive},
+	{"isc", Permissive},
+	{"libpng", Permissive},
+	{"mit", Permissive},
+	{"ncsa", Permissive},
+	{"ofl", Permissive},
+	{"openssl", Permissive},
+	{"php", Permissive},
+	{"psf", Permissive},
+	{"public domain", Permissive},
+	{"publicdomain", Permissive},
+	{"python", Permissive},
+	{"ruby", Permissive},
+	{"unlicense", Permissive},
+	{"upl", Permissive},
+	{"vim", Permissive},
+	{"wtfpl", Permissive},
+	{"x11", Permissive},
+	{"zlib", Permissive},
+	{"zpl", Permissive},
+}
+
+// Classify returns the class of a single license identifier. Custom licenses are Unknown unless
+// their name marks them as non-free (e.g., custom:Proprietary). Exceptions are not considered.
+func Classify(id *ID) Class {
+	name := strings.ToLower(id.Name)
+	for _, w := range nonFreeWords {
+		if strings.Contains(name, w) {
+			return NonFree
+		}
+	}
+
+	if id.Custom() {
+		return Unknown
+	}
+
+	for _, p := range classPrefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.class
+		}
+	}
+	return Unknown
+}
+
+// Requires returns whether every way of satisfying the expression involves a license for which
+// match returns true. All sub-expressions of an And must be satisfied, so an And requires a match
+// if any of its sub-expressions do. Only one sub-expression of an Or must be chosen, so an Or
+// requires a match only if all of its sub-expressions do.
+func Requires(e Expr, match func(*ID) bool) bool {
+	switch e := e.(type) {
+	case *ID:
+		return match(e)
+	case And:
+		for _, sub := range e {
+			if Requires(sub, match) {
+				return true
+			}
+		}
+		return false
+	case Or:
+		for _, sub := range e {
+			if !Requires(sub, match) {
+				return false
+			}
+		}
+		return len(e) > 0
+	}
+	return false
+}
+
+// RequiresClass returns whether every way of satisfying the expression involves a license of one
+// of the given classes.
+func RequiresClass(e Expr, classes ...Class) bool {
+	return Requires(e, func(id *ID) bool {
+		c := Classify(id)
+		for _, want := range classes {
+			if c == want {
+				return true
+			}
+		}
+		return false
+	})
+}
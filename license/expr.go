@@ -0,0 +1,292 @@
+// Package license parses the license field of XBPS packages and templates into SPDX-style
+// license expressions and classifies them.
+package license
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Expr is a node of a parsed license expression. It is one of *ID, And, or Or.
+type Expr interface {
+	// String returns the expression in SPDX form. Commas are not used, so the result may not
+	// match the original input.
+	String() string
+
+	isExpr()
+}
+
+// ID is a single license identifier, such as "MIT", "GPL-2.0-or-later", or "custom:Foo".
+type ID struct {
+	Name string
+	// Plus is true if the identifier was followed by a + (e.g., "GPL-2.0+").
+	Plus bool
+	// Exception is the exception named by a WITH clause, if any.
+	Exception string
+}
+
+// And is an expression whose sub-expressions all apply. Comma-separated licenses are parsed as
+// an And.
+type And []Expr
+
+// Or is an expression where only one sub-expression must be chosen.
+type Or []Expr
+
+func (*ID) isExpr() {}
+func (And) isExpr() {}
+func (Or) isExpr()  {}
+
+// Custom returns whether the identifier is an XBPS custom license (custom:<name>).
+func (id *ID) Custom() bool {
+	return strings.HasPrefix(id.Name, customPrefix)
+}
+
+func (id *ID) String() string {
+	s := id.Name
+	if id.Plus {
+		s += "+"
+	}
+	if id.Exception != "" {
+		s += " WITH " + id.Exception
+	}
+	return s
+}
+
+func (a And) String() string { return joinExprs(a, " AND ") }
+func (o Or) String() string  { return joinExprs(o, " OR ") }
+
+func joinExprs(exprs []Expr, sep string) string {
+	var sb strings.Builder
+	for i, e := range exprs {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		if _, ok := e.(*ID); ok {
+			sb.WriteString(e.String())
+			continue
+		}
+		sb.WriteByte('(')
+		sb.WriteString(e.String())
+		sb.WriteByte(')')
+	}
+	return sb.String()
+}
+
+// IDs returns all license identifiers in the expression, in the order they appear.
+func IDs(e Expr) []*ID {
+	var ids []*ID
+	walk(e, func(id *ID) { ids = append(ids, id) })
+	return ids
+}
+
+func walk(e Expr, fn func(*ID)) {
+	switch e := e.(type) {
+	case *ID:
+		fn(e)
+	case And:
+		for _, sub := range e {
+			walk(sub, fn)
+		}
+	case Or:
+		for _, sub := range e {
+			walk(sub, fn)
+		}
+	}
+}
+
+// Errors that may be in the Err field of *Error returned by Parse.
+var (
+	ErrEmpty      = errors.New("empty license")
+	ErrUnbalanced = errors.New("unbalanced parentheses")
+	ErrOperand    = errors.New("missing license before or after operator")
+	ErrException  = errors.New("missing exception after WITH")
+)
+
+// Error is an error returned by Parse.
+type Error struct {
+	License string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("license: cannot parse %q: %v", e.License, e.Err)
+}
+
+const customPrefix = "custom:"
+
+// Parse parses a license field into an expression.
+//
+// License fields are a comma-separated list of SPDX license expressions, all of which apply.
+// Expressions may use the operators AND, OR, and WITH (in any case) and parentheses, where AND
+// binds more tightly than OR. Adjacent identifiers with no operator between them are joined by
+// spaces into a single identifier to accommodate non-SPDX names like "Public Domain".
+//
+// All errors returned by Parse are of the type *Error.
+func Parse(s string) (Expr, error) {
+	p := &parser{toks: tokenize(s)}
+	if len(p.toks) == 0 {
+		return nil, &Error{s, ErrEmpty}
+	}
+
+	e, err := p.list()
+	if err == nil && p.peek() != "" {
+		err = ErrUnbalanced
+	}
+	if err != nil {
+		return nil, &Error{s, err}
+	}
+	return e, nil
+}
+
+// parser is a recursive descent parser over license expression tokens.
+type parser struct {
+	toks []string
+}
+
+func (p *parser) peek() string {
+	if len(p.toks) == 0 {
+		return ""
+	}
+	return p.toks[0]
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.toks = p.toks[1:]
+	}
+	return tok
+}
+
+// list := expr ("," expr)*
+func (p *parser) list() (Expr, error) {
+	return p.binary(",", p.expr, joinAnd)
+}
+
+// expr := term ("OR" term)*
+func (p *parser) expr() (Expr, error) {
+	return p.binary("OR", p.term, joinOr)
+}
+
+// term := factor ("AND" factor)*
+func (p *parser) term() (Expr, error) {
+	return p.binary("AND", p.factor, joinAnd)
+}
+
+func (p *parser) binary(op string, operand func() (Expr, error), join func([]Expr) Expr) (Expr, error) {
+	var exprs []Expr
+	for {
+		e, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if !strings.EqualFold(p.peek(), op) {
+			break
+		}
+		p.next()
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return join(exprs), nil
+}
+
+// joinAnd returns an And of exprs, merging nested Ands into it.
+func joinAnd(exprs []Expr) Expr {
+	var and And
+	for _, e := range exprs {
+		if sub, ok := e.(And); ok {
+			and = append(and, sub...)
+		} else {
+			and = append(and, e)
+		}
+	}
+	return and
+}
+
+// joinOr returns an Or of exprs, merging nested Ors into it.
+func joinOr(exprs []Expr) Expr {
+	var or Or
+	for _, e := range exprs {
+		if sub, ok := e.(Or); ok {
+			or = append(or, sub...)
+		} else {
+			or = append(or, e)
+		}
+	}
+	return or
+}
+
+// factor := "(" list ")" | ident ["WITH" ident]
+func (p *parser) factor() (Expr, error) {
+	switch tok := p.peek(); {
+	case tok == "(":
+		p.next()
+		e, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, ErrUnbalanced
+		}
+		return e, nil
+	case tok == ")":
+		return nil, ErrUnbalanced
+	case tok == "" || isOperator(tok):
+		return nil, ErrOperand
+	}
+
+	id := &ID{Name: p.ident()}
+	if strings.HasSuffix(id.Name, "+") {
+		id.Name, id.Plus = strings.TrimSuffix(id.Name, "+"), true
+	}
+	if strings.EqualFold(p.peek(), "WITH") {
+		p.next()
+		if id.Exception = p.ident(); id.Exception == "" {
+			return nil, ErrException
+		}
+	}
+	return id, nil
+}
+
+// ident consumes and returns consecutive non-operator tokens joined by spaces.
+func (p *parser) ident() string {
+	var words []string
+	for tok := p.peek(); tok != "" && tok != "(" && tok != ")" && !isOperator(tok); tok = p.peek() {
+		words = append(words, p.next())
+	}
+	return strings.Join(words, " ")
+}
+
+func isOperator(tok string) bool {
+	return tok == "," || strings.EqualFold(tok, "AND") || strings.EqualFold(tok, "OR") ||
+		strings.EqualFold(tok, "WITH")
+}
+
+// tokenize splits a license field into words, commas, and parentheses.
+func tokenize(s string) []string {
+	var toks []string
+	start := -1
+	for i, r := range s {
+		switch r {
+		case ' ', '\t', '\n', ',', '(', ')':
+			if start != -1 {
+				toks = append(toks, s[start:i])
+				start = -1
+			}
+			if r == ',' || r == '(' || r == ')' {
+				toks = append(toks, string(r))
+			}
+		default:
+			if start == -1 {
+				start = i
+			}
+		}
+	}
+	if start != -1 {
+		toks = append(toks, s[start:])
+	}
+	return toks
+}
@@ -0,0 +1,92 @@
+package license
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		In   string
+		Want string
+	}{
+		{"MIT", "MIT"},
+		{"GPL-2.0-or-later, MIT", "GPL-2.0-or-later AND MIT"},
+		{"GPL-2.0+", "GPL-2.0+"},
+		{"custom:Hybrid", "custom:Hybrid"},
+		{"Public Domain", "Public Domain"},
+		{"MIT OR Apache-2.0, BSD-3-Clause", "(MIT OR Apache-2.0) AND BSD-3-Clause"},
+		{"LGPL-2.1-only or MPL-1.1 and MIT", "LGPL-2.1-only OR (MPL-1.1 AND MIT)"},
+		{"(A AND B) AND C, D", "A AND B AND C AND D"},
+		{"GPL-2.0-only WITH Classpath-exception-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"},
+		{"(MIT OR ISC) AND (GPL-3.0-or-later OR custom:Foo)", "(MIT OR ISC) AND (GPL-3.0-or-later OR custom:Foo)"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.In, func(t *testing.T) {
+			e, err := Parse(c.In)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", c.In, err)
+			}
+			if got := e.String(); got != c.Want {
+				t.Fatalf("Parse(%q) = %q; want %q", c.In, got, c.Want)
+			}
+		})
+	}
+
+	fail := []struct {
+		In  string
+		Err error
+	}{
+		{"", ErrEmpty},
+		{" , ", ErrOperand},
+		{"MIT OR", ErrOperand},
+		{"AND MIT", ErrOperand},
+		{"(MIT", ErrUnbalanced},
+		{"MIT)", ErrUnbalanced},
+		{"GPL-2.0 WITH", ErrException},
+	}
+
+	for _, c := range fail {
+		c := c
+		t.Run(c.In, func(t *testing.T) {
+			_, err := Parse(c.In)
+			le, ok := err.(*Error)
+			if !ok || le == nil {
+				t.Fatalf("Parse(%q) error = %#v; want %T", c.In, err, &Error{})
+			}
+			if le.Err != c.Err {
+				t.Fatalf("Parse(%q) error = %v; want %v", c.In, le.Err, c.Err)
+			}
+		})
+	}
+}
+
+func TestRequiresClass(t *testing.T) {
+	cases := []struct {
+		In    string
+		Class Class
+		Want  bool
+	}{
+		{"MIT", Permissive, true},
+		{"GPL-2.0-or-later", Copyleft, true},
+		{"GPL-2.0-or-later, MIT", Copyleft, true},
+		{"GPL-2.0-or-later OR MIT", Copyleft, false},
+		{"GPL-3.0-only OR AGPL-3.0-only", Copyleft, true},
+		{"LGPL-2.1-or-later", Copyleft, false},
+		{"LGPL-2.1-or-later", WeakCopyleft, true},
+		{"custom:Proprietary", NonFree, true},
+		{"custom:Hybrid", Unknown, true},
+		{"CC-BY-NC-4.0", NonFree, true},
+		{"CC-BY-SA-4.0", Copyleft, true},
+		{"Public Domain", Permissive, true},
+	}
+
+	for _, c := range cases {
+		e, err := Parse(c.In)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", c.In, err)
+		}
+		if got := RequiresClass(e, c.Class); got != c.Want {
+			t.Errorf("RequiresClass(%q, %v) = %t; want %t", c.In, c.Class, got, c.Want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package xbps
+
+import "strings"
+
+// OrphanEmail is the maintainer email address of orphaned packages.
+const OrphanEmail = "orphan@voidlinux.org"
+
+// Maintainer is the name and email address of a package maintainer.
+type Maintainer struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// ParseMaintainer parses a maintainer string of the form "Name <email>". Either part may be
+// omitted, in which case a string containing an @ is taken to be an email address and anything
+// else a name. ParseMaintainer never fails; malformed input is parsed on a best-effort basis.
+func ParseMaintainer(s string) Maintainer {
+	s = strings.TrimSpace(s)
+	open := strings.IndexByte(s, '<')
+	if open == -1 {
+		if strings.ContainsRune(s, '@') && !strings.ContainsAny(s, " \t") {
+			return Maintainer{Email: s}
+		}
+		return Maintainer{Name: s}
+	}
+
+	email := s[open+1:]
+	if end := strings.IndexByte(email, '>'); end != -1 {
+		email = email[:end]
+	}
+	return Maintainer{
+		Name:  strings.TrimSpace(s[:open]),
+		Email: strings.TrimSpace(email),
+	}
+}
+
+// Key returns a key identifying the maintainer: their email address in lower case or, if they
+// have no email address, their name.
+func (m Maintainer) Key() string {
+	if m.Email != "" {
+		return strings.ToLower(m.Email)
+	}
+	return m.Name
+}
+
+// Orphaned returns whether the maintainer is the orphan maintainer (OrphanEmail).
+func (m Maintainer) Orphaned() bool {
+	return strings.EqualFold(m.Email, OrphanEmail)
+}
+
+// String returns the maintainer as a string of the form "Name <email>".
+func (m Maintainer) String() string {
+	switch {
+	case m.Email == "":
+		return m.Name
+	case m.Name == "":
+		return "<" + m.Email + ">"
+	}
+	return m.Name + " <" + m.Email + ">"
+}
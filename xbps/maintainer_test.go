@@ -0,0 +1,28 @@
+package xbps
+
+import "testing"
+
+func TestParseMaintainer(t *testing.T) {
+	cases := []struct {
+		In   string
+		Want Maintainer
+	}{
+		{"Noel Cower <ncower@gmail.com>", Maintainer{"Noel Cower", "ncower@gmail.com"}},
+		{"  Orphaned   <orphan@voidlinux.org> ", Maintainer{"Orphaned", "orphan@voidlinux.org"}},
+		{"<someone@example.org>", Maintainer{"", "someone@example.org"}},
+		{"someone@example.org", Maintainer{"", "someone@example.org"}},
+		{"Some One", Maintainer{"Some One", ""}},
+		{"Some One <someone@example.org", Maintainer{"Some One", "someone@example.org"}},
+		{"", Maintainer{}},
+	}
+
+	for _, c := range cases {
+		if got := ParseMaintainer(c.In); got != c.Want {
+			t.Errorf("ParseMaintainer(%q) = %#+v; want %#+v", c.In, got, c.Want)
+		}
+	}
+
+	if m := ParseMaintainer("Orphaned <ORPHAN@voidlinux.org>"); !m.Orphaned() {
+		t.Errorf("%#+v.Orphaned() = false; want true", m)
+	}
+}
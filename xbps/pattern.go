@@ -0,0 +1,187 @@
+package xbps
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PatternKind identifies how a Pattern matches package versions.
+type PatternKind int
+
+// Kinds of package patterns accepted by ParsePattern.
+const (
+	// PatternName matches any version of a package (e.g., "foo").
+	PatternName PatternKind = iota
+	// PatternExact matches a single pkgver (e.g., "foo-1.0_1").
+	PatternExact
+	// PatternGlob matches versions using a shell glob (e.g., "foo-1.[0-9]*").
+	PatternGlob
+	// PatternDewey matches versions using one or two relational constraints
+	// (e.g., "foo>=1.0" or "foo>=1.0<2.0").
+	PatternDewey
+)
+
+var patternKindNames = [...]string{
+	PatternName:  "name",
+	PatternExact: "exact",
+	PatternGlob:  "glob",
+	PatternDewey: "dewey",
+}
+
+func (k PatternKind) String() string {
+	if k < 0 || int(k) >= len(patternKindNames) {
+		return fmt.Sprintf("PatternKind(%d)", int(k))
+	}
+	return patternKindNames[k]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k PatternKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Constraint is a single relational version constraint of a dewey pattern.
+type Constraint struct {
+	Op      string `json:"op"`
+	Version string `json:"version"`
+}
+
+func (c Constraint) String() string {
+	return c.Op + c.Version
+}
+
+// Pattern is a parsed XBPS package pattern, as found in run_depends and template depends.
+type Pattern struct {
+	Kind PatternKind `json:"kind"`
+	Name string      `json:"name"`
+	// Version is the version (and revision) of an exact pattern, or the version glob of a glob
+	// pattern. It is empty for name and dewey patterns.
+	Version string `json:"version,omitempty"`
+	// Constraints holds the relational constraints of a dewey pattern.
+	Constraints []Constraint `json:"constraints,omitempty"`
+}
+
+// Errors that may be in the Err field of *PatternError returned by ParsePattern.
+var (
+	ErrPatternNoName     = errors.New("missing name")
+	ErrPatternNoVersion  = errors.New("missing version")
+	ErrPatternBadOp      = errors.New("invalid version constraint operator")
+	ErrPatternBadName    = errors.New("name must not contain whitespace or the characters <, >, *, ?, or [")
+	ErrPatternConstraint = errors.New("too many version constraints")
+)
+
+// PatternError is an error returned by ParsePattern.
+type PatternError struct {
+	Pattern string
+	Err     error
+}
+
+func (e *PatternError) Error() string {
+	return fmt.Sprintf("pattern: cannot parse %q: %v", e.Pattern, e.Err)
+}
+
+const (
+	deweyChars = "<>"
+	globChars  = "*?["
+)
+
+// ParsePattern parses a package pattern. Patterns take one of the following forms:
+//
+//	foo              any version of foo
+//	foo-1.0_1        exactly pkgver foo-1.0_1
+//	foo-1.[0-9]*_1   pkgvers of foo matching the glob 1.[0-9]*_1
+//	foo>=1.0         versions of foo satisfying up to two constraints (<, <=, >, >=)
+//
+// All errors returned by ParsePattern are of the type *PatternError.
+func ParsePattern(s string) (pat Pattern, err error) {
+	if strings.ContainsAny(s, " \t\n") {
+		return pat, &PatternError{s, ErrPatternBadName}
+	}
+
+	if i := strings.IndexAny(s, deweyChars); i != -1 {
+		return parseDeweyPattern(s, i)
+	}
+
+	if i := strings.IndexAny(s, globChars); i != -1 {
+		sep := strings.LastIndexByte(s[:i], '-')
+		if sep == -1 {
+			return pat, &PatternError{s, ErrPatternBadName}
+		} else if sep == 0 {
+			return pat, &PatternError{s, ErrPatternNoName}
+		}
+		return Pattern{Kind: PatternGlob, Name: s[:sep], Version: s[sep+1:]}, nil
+	}
+
+	if pkgver, err := ParsePkgVer(s); err == nil {
+		return Pattern{
+			Kind:    PatternExact,
+			Name:    pkgver.Name,
+			Version: s[len(pkgver.Name)+1:],
+		}, nil
+	}
+
+	if s == "" {
+		return pat, &PatternError{s, ErrPatternNoName}
+	}
+	return Pattern{Kind: PatternName, Name: s}, nil
+}
+
+func parseDeweyPattern(s string, sep int) (pat Pattern, err error) {
+	if sep == 0 {
+		return pat, &PatternError{s, ErrPatternNoName}
+	} else if s[sep-1] == '=' {
+		return pat, &PatternError{s, ErrPatternBadOp}
+	} else if strings.ContainsAny(s[:sep], globChars) {
+		return pat, &PatternError{s, ErrPatternBadName}
+	}
+
+	pat = Pattern{Kind: PatternDewey, Name: s[:sep]}
+	for rest := s[sep:]; rest != ""; {
+		if len(pat.Constraints) == 2 {
+			return Pattern{}, &PatternError{s, ErrPatternConstraint}
+		}
+
+		op := rest[:1]
+		if len(rest) > 1 && rest[1] == '=' {
+			op = rest[:2]
+		}
+		if op != "<" && op != ">" && op != "<=" && op != ">=" {
+			return Pattern{}, &PatternError{s, ErrPatternBadOp}
+		}
+		rest = rest[len(op):]
+
+		end := strings.IndexAny(rest, deweyChars)
+		if end == -1 {
+			end = len(rest)
+		}
+		ver := rest[:end]
+		if ver == "" {
+			return Pattern{}, &PatternError{s, ErrPatternNoVersion}
+		} else if strings.ContainsAny(ver, "=") {
+			return Pattern{}, &PatternError{s, ErrPatternBadOp}
+		}
+		rest = rest[end:]
+
+		pat.Constraints = append(pat.Constraints, Constraint{op, ver})
+	}
+
+	return pat, nil
+}
+
+// String returns the pattern in the form it was parsed from.
+func (p Pattern) String() string {
+	switch p.Kind {
+	case PatternExact, PatternGlob:
+		return p.Name + "-" + p.Version
+	case PatternDewey:
+		var sb strings.Builder
+		sb.WriteString(p.Name)
+		for _, c := range p.Constraints {
+			sb.WriteString(c.String())
+		}
+		return sb.String()
+	default:
+		return p.Name
+	}
+}
@@ -0,0 +1,81 @@
+package xbps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	succ := []struct {
+		In   string
+		Want Pattern
+	}{
+		{"glibc", Pattern{Kind: PatternName, Name: "glibc"}},
+		{"libfoo-32bit", Pattern{Kind: PatternName, Name: "libfoo-32bit"}},
+		{"glibc-2.29_1", Pattern{Kind: PatternExact, Name: "glibc", Version: "2.29_1"}},
+		{"apr-util-ldap-1.6.1_6", Pattern{Kind: PatternExact, Name: "apr-util-ldap", Version: "1.6.1_6"}},
+		{"perl-[0-9]*", Pattern{Kind: PatternGlob, Name: "perl", Version: "[0-9]*"}},
+		{"foo-bar-1.?_1", Pattern{Kind: PatternGlob, Name: "foo-bar", Version: "1.?_1"}},
+		{"glibc>=2.29_1", Pattern{
+			Kind:        PatternDewey,
+			Name:        "glibc",
+			Constraints: []Constraint{{">=", "2.29_1"}},
+		}},
+		{"python3<3.8", Pattern{
+			Kind:        PatternDewey,
+			Name:        "python3",
+			Constraints: []Constraint{{"<", "3.8"}},
+		}},
+		{"qt5-core>5.0<=5.12.1_2", Pattern{
+			Kind:        PatternDewey,
+			Name:        "qt5-core",
+			Constraints: []Constraint{{">", "5.0"}, {"<=", "5.12.1_2"}},
+		}},
+	}
+
+	for _, c := range succ {
+		c := c
+		t.Run(c.In, func(t *testing.T) {
+			pat, err := ParsePattern(c.In)
+			if err != nil {
+				t.Fatalf("ParsePattern(%q) error = %v", c.In, err)
+			}
+			if !reflect.DeepEqual(pat, c.Want) {
+				t.Fatalf("ParsePattern(%q): got %#+v; want %#+v", c.In, pat, c.Want)
+			}
+			if got := pat.String(); got != c.In {
+				t.Fatalf("%#v.String() = %q; want %q", pat, got, c.In)
+			}
+		})
+	}
+
+	fail := []struct {
+		In  string
+		Err error
+	}{
+		{"", ErrPatternNoName},
+		{">=1.0", ErrPatternNoName},
+		{"-[0-9]*", ErrPatternNoName},
+		{"foo*", ErrPatternBadName},
+		{"foo bar", ErrPatternBadName},
+		{"foo>=", ErrPatternNoVersion},
+		{"foo>=1.0<", ErrPatternNoVersion},
+		{"foo=>1.0", ErrPatternBadOp},
+		{"foo>==1.0", ErrPatternBadOp},
+		{"foo>1<2>3", ErrPatternConstraint},
+	}
+
+	for _, c := range fail {
+		c := c
+		t.Run(c.In, func(t *testing.T) {
+			_, err := ParsePattern(c.In)
+			pe, ok := err.(*PatternError)
+			if !ok || pe == nil {
+				t.Fatalf("ParsePattern(%q) error = %#v; want %T", c.In, err, &PatternError{})
+			}
+			if pe.Err != c.Err {
+				t.Fatalf("ParsePattern(%q) error = %v; want %v", c.In, pe.Err, c.Err)
+			}
+		})
+	}
+}
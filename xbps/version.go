@@ -0,0 +1,129 @@
+package xbps
+
+import (
+	"strings"
+)
+
+// Values of version modifiers, as used by XBPS's dewey comparison.
+const (
+	deweyAlpha = -3
+	deweyBeta  = -2
+	deweyRC    = -1
+	deweyDot   = 0
+)
+
+var deweyModifiers = []struct {
+	word  string
+	value int
+}{
+	{"alpha", deweyAlpha},
+	{"beta", deweyBeta},
+	{"pre", deweyRC},
+	{"rc", deweyRC},
+	{"pl", deweyDot},
+	{".", deweyDot},
+}
+
+// deweyVersion is a version split into numeric components, along with its revision.
+type deweyVersion struct {
+	parts    []int
+	revision int
+}
+
+// parseDewey splits a version of the form <version>[_<revision>] into components the same way
+// XBPS does: numbers are components of their own, alpha, beta, pre, and rc sort below a plain
+// release, and other letters are treated as a dot followed by their position in the alphabet
+// (so 1.0a is 1.0.0.1). Characters that are none of these are ignored.
+func parseDewey(s string) deweyVersion {
+	var v deweyVersion
+	for len(s) > 0 {
+		c := s[0]
+		switch {
+		case c == '_':
+			s = s[1:]
+			n := 0
+			for len(s) > 0 && isDigit(s[0]) {
+				n = n*10 + int(s[0]-'0')
+				s = s[1:]
+			}
+			v.revision = n
+			continue
+		case isDigit(c):
+			n := 0
+			for len(s) > 0 && isDigit(s[0]) {
+				n = n*10 + int(s[0]-'0')
+				s = s[1:]
+			}
+			v.parts = append(v.parts, n)
+			continue
+		}
+
+		lower := strings.ToLower(s)
+		matched := false
+		for _, m := range deweyModifiers {
+			if strings.HasPrefix(lower, m.word) {
+				v.parts = append(v.parts, m.value)
+				s = s[len(m.word):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		if lc := lower[0]; lc >= 'a' && lc <= 'z' {
+			v.parts = append(v.parts, deweyDot, int(lc-'a')+1)
+		}
+		s = s[1:]
+	}
+	return v
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// CompareVersions compares two versions of the form <version>[_<revision>] the same way XBPS
+// does, returning -1 if a is older than b, 1 if a is newer than b, and 0 if they are equal.
+// Missing trailing components compare as zero, so 1.0 and 1.0.0 are equal, and revisions are
+// compared only if versions are equal.
+func CompareVersions(a, b string) int {
+	va, vb := parseDewey(a), parseDewey(b)
+	n := len(va.parts)
+	if len(vb.parts) > n {
+		n = len(vb.parts)
+	}
+	for i := 0; i < n; i++ {
+		var pa, pb int
+		if i < len(va.parts) {
+			pa = va.parts[i]
+		}
+		if i < len(vb.parts) {
+			pb = vb.parts[i]
+		}
+		if c := compareInts(pa, pb); c != 0 {
+			return c
+		}
+	}
+	return compareInts(va.revision, vb.revision)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Compare compares the version and revision of two PkgVers as CompareVersions does. Names are
+// not compared.
+func (p PkgVer) Compare(o PkgVer) int {
+	if c := CompareVersions(p.Version, o.Version); c != 0 {
+		return c
+	}
+	return compareInts(p.Revision, o.Revision)
+}
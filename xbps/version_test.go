@@ -0,0 +1,48 @@
+package xbps
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		A, B string
+		Want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"2.0", "10.0", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0alpha", "1.0beta", -1},
+		{"1.0beta2", "1.0rc1", -1},
+		{"1.0pre1", "1.0rc1", 0},
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0b", -1},
+		{"1.0pl1", "1.0.1", 0},
+		{"1.0_1", "1.0_2", -1},
+		{"1.1_1", "1.0_2", 1},
+		{"1.0_10", "1.0_9", 1},
+		{"20190101", "20181231", 1},
+	}
+	for _, c := range cases {
+		if got := CompareVersions(c.A, c.B); got != c.Want {
+			t.Errorf("CompareVersions(%q, %q) = %d; want %d", c.A, c.B, got, c.Want)
+		}
+		if got := CompareVersions(c.B, c.A); got != -c.Want {
+			t.Errorf("CompareVersions(%q, %q) = %d; want %d", c.B, c.A, got, -c.Want)
+		}
+	}
+}
+
+func TestPkgVerCompare(t *testing.T) {
+	a := PkgVer{"foo", "1.2", 3}
+	if got := a.Compare(PkgVer{"bar", "1.2", 3}); got != 0 {
+		t.Errorf("Compare with equal version = %d; want 0", got)
+	}
+	if got := a.Compare(PkgVer{"foo", "1.2", 4}); got != -1 {
+		t.Errorf("Compare with newer revision = %d; want -1", got)
+	}
+	if got := a.Compare(PkgVer{"foo", "1.1", 9}); got != 1 {
+		t.Errorf("Compare with older version = %d; want 1", got)
+	}
+}
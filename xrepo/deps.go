@@ -0,0 +1,102 @@
+package xrepo
+
+import (
+	"go.spiff.io/nxtools/depgraph"
+	"go.spiff.io/nxtools/xbps"
+)
+
+// indexProviders rebuilds the virtual package and shared library provider indices from the
+// receiver's package index.
+func (rd *RepoData) indexProviders() {
+	virtual := map[string]Packages{}
+	shlibs := map[string]Packages{}
+	for _, p := range rd.index {
+		for _, v := range p.Provides {
+			name := v
+			if pkgver, err := xbps.ParsePkgVer(v); err == nil {
+				name = pkgver.Name
+			}
+			virtual[name] = append(virtual[name], p)
+		}
+		for _, so := range p.ShlibProvides {
+			shlibs[so] = append(shlibs[so], p)
+		}
+	}
+	rd.virtual = virtual
+	rd.shlibs = shlibs
+}
+
+// Providers returns all packages that provide the virtual package identified by name.
+// Callers must not modify the returned slice or packages.
+func (rd *RepoData) Providers(name string) Packages {
+	if rd == nil {
+		return nil
+	}
+	return rd.virtual[name]
+}
+
+// ShlibProviders returns all packages that provide the given shared library (e.g.,
+// "libc.so.6"). Callers must not modify the returned slice or packages.
+func (rd *RepoData) ShlibProviders(soname string) Packages {
+	if rd == nil {
+		return nil
+	}
+	return rd.shlibs[soname]
+}
+
+// Resolve returns the packages satisfying the name of a dependency pattern, such as an entry of a
+// package's run_depends. If a real package of that name exists, only that package is returned.
+// Otherwise, any providers of a virtual package of that name are returned.
+//
+// Resolve only considers the name of the pattern. An error is returned if the pattern cannot be
+// parsed.
+func (rd *RepoData) Resolve(pattern string) (Packages, error) {
+	pat, err := xbps.ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if p := rd.Package(pat.Name); p != nil {
+		return Packages{p}, nil
+	}
+	return rd.Providers(pat.Name), nil
+}
+
+// Graph returns a dependency graph of all packages in the receiver. Nodes are package names.
+// If no kinds are given, both run (run_depends) and shlib (shlib-requires) edges are included;
+// otherwise, only edges of the given kinds are included. Dependencies that cannot be resolved are
+// not included in the graph.
+func (rd *RepoData) Graph(kinds ...depgraph.Kind) *depgraph.Graph {
+	run, shlib := len(kinds) == 0, len(kinds) == 0
+	for _, k := range kinds {
+		switch k {
+		case depgraph.Run:
+			run = true
+		case depgraph.Shlib:
+			shlib = true
+		}
+	}
+
+	g := depgraph.New()
+	for _, p := range rd.Index() {
+		g.AddNode(p.Name)
+		if run {
+			for _, dep := range p.RunDepends {
+				deps, _ := rd.Resolve(dep)
+				for _, d := range deps {
+					g.AddEdge(p.Name, d.Name, depgraph.Run)
+				}
+			}
+		}
+		if shlib {
+			for _, so := range p.ShlibRequires {
+				for _, d := range rd.ShlibProviders(so) {
+					if d == p {
+						continue
+					}
+					g.AddEdge(p.Name, d.Name, depgraph.Shlib)
+				}
+			}
+		}
+	}
+	return g
+}
@@ -0,0 +1,69 @@
+package xrepo
+
+import (
+	"go.spiff.io/nxtools/depgraph"
+	"go.spiff.io/nxtools/license"
+)
+
+// LicenseExpr parses the package's license field as a license expression.
+func (p *Package) LicenseExpr() (license.Expr, error) {
+	return license.Parse(p.License)
+}
+
+// LicensePolicy describes the license classes not permitted in a set of packages.
+type LicensePolicy struct {
+	// Deny is the set of license classes that violate the policy. A package violates the
+	// policy if every way of satisfying its license requires a license of a denied class.
+	// Packages whose license cannot be parsed are treated as license.Unknown.
+	Deny []license.Class
+	// Kinds is the set of dependency kinds followed from the root packages. If empty, both run
+	// and shlib dependencies are followed.
+	Kinds []depgraph.Kind
+}
+
+// LicenseViolation describes a package that violates a LicensePolicy.
+type LicenseViolation struct {
+	Package string          `json:"package"`
+	License string          `json:"license"`
+	Classes []license.Class `json:"classes"`
+	// Error is set if the package's license could not be parsed.
+	Error string `json:"error,omitempty"`
+	// Path is the shortest dependency path from a root package to the violating package.
+	Path []string `json:"path"`
+}
+
+// CheckLicenses returns a violation for every package in the dependency closure of the given root
+// packages that violates the policy. If no roots are given, every package is checked on its own.
+// Violations are sorted by package name.
+func (rd *RepoData) CheckLicenses(policy LicensePolicy, roots ...string) []LicenseViolation {
+	g := rd.Graph(policy.Kinds...)
+	if len(roots) == 0 {
+		roots = rd.NameIndex()
+	}
+	paths := g.ShortestPaths(roots...)
+
+	var violations []LicenseViolation
+	for _, name := range paths.Reachable() {
+		p := rd.Package(name)
+		if p == nil {
+			continue
+		}
+
+		v := LicenseViolation{Package: name, License: p.License}
+		expr, err := p.LicenseExpr()
+		for _, c := range policy.Deny {
+			if err != nil && c == license.Unknown || err == nil && license.RequiresClass(expr, c) {
+				v.Classes = append(v.Classes, c)
+			}
+		}
+		if len(v.Classes) == 0 {
+			continue
+		}
+		if err != nil {
+			v.Error = err.Error()
+		}
+		v.Path = paths.To(name)
+		violations = append(violations, v)
+	}
+	return violations
+}
@@ -0,0 +1,57 @@
+package xrepo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.spiff.io/nxtools/depgraph"
+	"go.spiff.io/nxtools/license"
+)
+
+func TestCheckLicenses(t *testing.T) {
+	rd := testRepoData(t, "current", testPackages{
+		"app":  {"license": "MIT", "run_depends": []string{"lib>=1.0_1", "tool"}, "shlib-requires": []string{"libblob.so.1"}},
+		"lib":  {"license": "GPL-3.0-or-later", "run_depends": []string{"libc"}},
+		"libc": {"license": "LGPL-2.1-or-later OR MIT"},
+		"tool": {"license": "custom:Tool", "run_depends": []string{"libc"}},
+		"blob": {"license": "custom:Proprietary", "shlib-provides": []string{"libblob.so.1"}},
+		"gpl":  {"license": "GPL-2.0-only OR GPL-3.0-only"},
+		"bad":  {"license": "MIT AND ("},
+	})
+
+	_, parseErr := license.Parse("MIT AND (")
+	if parseErr == nil {
+		t.Fatal(`license.Parse("MIT AND (") succeeded; want an error`)
+	}
+
+	copyleftUnknown := []license.Class{license.Copyleft, license.Unknown}
+	cases := []struct {
+		Name   string
+		Policy LicensePolicy
+		Roots  []string
+		Want   []LicenseViolation
+	}{
+		{"closure", LicensePolicy{Deny: copyleftUnknown}, []string{"app"}, []LicenseViolation{
+			{Package: "lib", License: "GPL-3.0-or-later", Classes: []license.Class{license.Copyleft}, Path: []string{"app", "lib"}},
+			{Package: "tool", License: "custom:Tool", Classes: []license.Class{license.Unknown}, Path: []string{"app", "tool"}},
+		}},
+		{"nonfree by shlib", LicensePolicy{Deny: []license.Class{license.NonFree}}, []string{"app"}, []LicenseViolation{
+			{Package: "blob", License: "custom:Proprietary", Classes: []license.Class{license.NonFree}, Path: []string{"app", "blob"}},
+		}},
+		{"run only", LicensePolicy{Deny: []license.Class{license.NonFree}, Kinds: []depgraph.Kind{depgraph.Run}}, []string{"app"}, nil},
+		{"all packages", LicensePolicy{Deny: copyleftUnknown}, nil, []LicenseViolation{
+			{Package: "bad", License: "MIT AND (", Classes: []license.Class{license.Unknown}, Error: parseErr.Error(), Path: []string{"bad"}},
+			{Package: "gpl", License: "GPL-2.0-only OR GPL-3.0-only", Classes: []license.Class{license.Copyleft}, Path: []string{"gpl"}},
+			{Package: "lib", License: "GPL-3.0-or-later", Classes: []license.Class{license.Copyleft}, Path: []string{"lib"}},
+			{Package: "tool", License: "custom:Tool", Classes: []license.Class{license.Unknown}, Path: []string{"tool"}},
+		}},
+		{"weak copyleft alternative", LicensePolicy{Deny: []license.Class{license.WeakCopyleft}}, []string{"app"}, nil},
+		{"unknown root", LicensePolicy{Deny: copyleftUnknown}, []string{"missing"}, nil},
+	}
+	for _, c := range cases {
+		got := rd.CheckLicenses(c.Policy, c.Roots...)
+		if !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("%s: CheckLicenses(%v, %q) = %+v; want %+v", c.Name, c.Policy, c.Roots, got, c.Want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package xrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go.spiff.io/nxtools/depgraph"
+)
+
+// Severity is the severity of a lint issue.
+type Severity int
+
+// Lint issue severities, in increasing order of severity.
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = [...]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// ParseSeverity parses the name of a severity (info, warning, or error).
+func ParseSeverity(s string) (Severity, error) {
+	for sev, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return Severity(sev), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid severity: %q", s)
+}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(p []byte) error {
+	sev, err := ParseSeverity(string(p))
+	if err != nil {
+		return err
+	}
+	*s = sev
+	return nil
+}
+
+// LintIssue is a single problem found by a LintCheck.
+type LintIssue struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Package  string   `json:"package,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	if i.Package == "" {
+		return fmt.Sprintf("%s %s: %s", i.Severity, i.Check, i.Message)
+	}
+	return fmt.Sprintf("%s %s %s: %s", i.Severity, i.Check, i.Package, i.Message)
+}
+
+// LintCheck is a named check that finds issues in repodata.
+type LintCheck interface {
+	// Name returns the name of the check. Names should be unique.
+	Name() string
+	// Lint returns all issues found in rd. It must not modify rd or its packages.
+	Lint(rd *RepoData) []LintIssue
+}
+
+// PackageCheck is a LintCheck that reports an issue for every package matched by a filter.
+type PackageCheck struct {
+	CheckName string
+	Severity  Severity
+	// Match returns true for packages that have an issue.
+	Match FilterFunc
+	// Message describes the issue of a matched package.
+	Message func(*Package) string
+}
+
+var _ LintCheck = (*PackageCheck)(nil)
+
+// Name implements LintCheck.
+func (c *PackageCheck) Name() string {
+	return c.CheckName
+}
+
+// Lint implements LintCheck.
+func (c *PackageCheck) Lint(rd *RepoData) []LintIssue {
+	var issues []LintIssue
+	for _, p := range rd.Index().Filter(c.Match) {
+		issues = append(issues, LintIssue{
+			Check:    c.CheckName,
+			Severity: c.Severity,
+			Package:  p.Name,
+			Message:  c.Message(p),
+		})
+	}
+	return issues
+}
+
+// RepoCheck is a LintCheck implemented by a function over all repodata.
+type RepoCheck struct {
+	CheckName string
+	Func      func(rd *RepoData) []LintIssue
+}
+
+var _ LintCheck = (*RepoCheck)(nil)
+
+// Name implements LintCheck.
+func (c *RepoCheck) Name() string {
+	return c.CheckName
+}
+
+// Lint implements LintCheck.
+func (c *RepoCheck) Lint(rd *RepoData) []LintIssue {
+	return c.Func(rd)
+}
+
+// LintReport is the result of running lint checks over repodata.
+type LintReport struct {
+	Issues []LintIssue
+}
+
+// Lint runs all checks over rd and returns a report of their issues. Issues are sorted by
+// severity (most severe first), check name, and package name. If no checks are given,
+// DefaultLintChecks is used.
+func Lint(rd *RepoData, checks ...LintCheck) *LintReport {
+	if len(checks) == 0 {
+		checks = DefaultLintChecks()
+	}
+
+	var issues []LintIssue
+	for _, c := range checks {
+		for _, issue := range c.Lint(rd) {
+			if issue.Check == "" {
+				issue.Check = c.Name()
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		} else if a.Check != b.Check {
+			return a.Check < b.Check
+		}
+		return a.Package < b.Package
+	})
+
+	return &LintReport{Issues: issues}
+}
+
+// Filter returns a new report containing only issues of at least the given severity.
+func (r *LintReport) Filter(min Severity) *LintReport {
+	issues := make([]LintIssue, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		if issue.Severity >= min {
+			issues = append(issues, issue)
+		}
+	}
+	return &LintReport{Issues: issues}
+}
+
+// Count returns the number of issues of the given severity.
+func (r *LintReport) Count(sev Severity) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == sev {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText writes the report to w as one issue per line.
+func (r *LintReport) WriteText(w io.Writer) error {
+	for _, issue := range r.Issues {
+		if _, err := fmt.Fprintln(w, issue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report to w as one JSON object per issue, separated by newlines.
+func (r *LintReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, issue := range r.Issues {
+		if err := enc.Encode(issue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultLintChecks returns the set of lint checks provided by xrepo.
+func DefaultLintChecks() []LintCheck {
+	return []LintCheck{
+		&PackageCheck{
+			CheckName: "maintainer",
+			Severity:  SeverityWarning,
+			Match:     func(p *Package) bool { return strings.TrimSpace(p.Maintainer) == "" },
+			Message:   func(*Package) string { return "no maintainer" },
+		},
+		&PackageCheck{
+			CheckName: "license",
+			Severity:  SeverityError,
+			Match:     func(p *Package) bool { return strings.TrimSpace(p.License) == "" },
+			Message:   func(*Package) string { return "no license" },
+		},
+		&PackageCheck{
+			CheckName: "homepage",
+			Severity:  SeverityWarning,
+			Match:     func(p *Package) bool { return p.Homepage != "" && homepageURL(p) == nil },
+			Message: func(p *Package) string {
+				return fmt.Sprintf("homepage is not a valid URL: %q", p.Homepage)
+			},
+		},
+		&PackageCheck{
+			CheckName: "homepage-http",
+			Severity:  SeverityInfo,
+			Match: func(p *Package) bool {
+				u := homepageURL(p)
+				return u != nil && strings.EqualFold(u.Scheme, "http")
+			},
+			Message: func(p *Package) string {
+				return fmt.Sprintf("homepage uses http: %s", p.Homepage)
+			},
+		},
+		&PackageCheck{
+			CheckName: "build-date",
+			Severity:  SeverityError,
+			Match: func(p *Package) bool {
+				return p.BuildDate.Time().After(time.Now())
+			},
+			Message: func(p *Package) string {
+				return fmt.Sprintf("build-date is in the future: %s",
+					p.BuildDate.Time().Format(time.RFC3339))
+			},
+		},
+		&RepoCheck{CheckName: "run-depends", Func: lintRunDepends},
+		&RepoCheck{CheckName: "run-cycles", Func: lintRunCycles},
+		&RepoCheck{CheckName: "shlib-providers", Func: lintShlibProviders},
+	}
+}
+
+// homepageURL returns the package's homepage if it is an absolute URL with a scheme and host, or
+// nil otherwise. Homepages are read as strings, so that one that doesn't parse is reported here
+// instead of failing to read the repository.
+func homepageURL(p *Package) *url.URL {
+	u, err := url.Parse(p.Homepage)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil
+	}
+	return u
+}
+
+// LintChecks returns the checks from DefaultLintChecks with the given names, in the order given.
+// If no names are given, all default checks are returned.
+func LintChecks(names ...string) ([]LintCheck, error) {
+	defaults := DefaultLintChecks()
+	if len(names) == 0 {
+		return defaults, nil
+	}
+
+	byName := make(map[string]LintCheck, len(defaults))
+	for _, c := range defaults {
+		byName[c.Name()] = c
+	}
+
+	checks := make([]LintCheck, 0, len(names))
+	for _, name := range names {
+		c, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown lint check: %q", name)
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// lintRunDepends reports run_depends entries that cannot be parsed or do not name a package or
+// virtual package in the repodata.
+func lintRunDepends(rd *RepoData) []LintIssue {
+	var issues []LintIssue
+	for _, p := range rd.Index() {
+		for _, dep := range p.RunDepends {
+			deps, err := rd.Resolve(dep)
+			if err != nil {
+				issues = append(issues, LintIssue{
+					Severity: SeverityError,
+					Package:  p.Name,
+					Message:  err.Error(),
+				})
+			} else if len(deps) == 0 {
+				issues = append(issues, LintIssue{
+					Severity: SeverityError,
+					Package:  p.Name,
+					Message:  fmt.Sprintf("run_depends names nonexistent package: %s", dep),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// lintRunCycles reports circular run dependencies. One issue is reported per cycle.
+func lintRunCycles(rd *RepoData) []LintIssue {
+	var issues []LintIssue
+	for _, cycle := range rd.Graph(depgraph.Run).Cycles() {
+		issues = append(issues, LintIssue{
+			Severity: SeverityWarning,
+			Package:  cycle[0],
+			Message:  "circular run dependency: " + strings.Join(cycle, " -> "),
+		})
+	}
+	return issues
+}
+
+// lintShlibProviders reports shared libraries provided by more than one package. One issue is
+// reported per shared library.
+func lintShlibProviders(rd *RepoData) []LintIssue {
+	sonames := make([]string, 0, len(rd.shlibs))
+	for so, providers := range rd.shlibs {
+		if len(providers) > 1 {
+			sonames = append(sonames, so)
+		}
+	}
+	sort.Strings(sonames)
+
+	issues := make([]LintIssue, 0, len(sonames))
+	for _, so := range sonames {
+		providers := rd.shlibs[so]
+		names := make([]string, len(providers))
+		for i, p := range providers {
+			names[i] = p.Name
+		}
+		issues = append(issues, LintIssue{
+			Severity: SeverityWarning,
+			Package:  names[0],
+			Message:  fmt.Sprintf("%s is provided by multiple packages: %s", so, strings.Join(names, ", ")),
+		})
+	}
+	return issues
+}
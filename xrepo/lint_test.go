@@ -0,0 +1,122 @@
+package xrepo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaultLintChecks(t *testing.T) {
+	rd := testRepoData(t, "current", testPackages{
+		"a": {
+			"maintainer":     "A <a@example.org>",
+			"license":        "MIT",
+			"homepage":       "https://a.org",
+			"build-date":     "2019-01-01 12:00 UTC",
+			"run_depends":    []string{"b>=1.0_1"},
+			"shlib-provides": []string{"libx.so.1"},
+		},
+		"b": {
+			"license":        "MIT",
+			"homepage":       "http://b.org",
+			"build-date":     "2019-01-01 12:00 UTC",
+			"run_depends":    []string{"a"},
+			"shlib-provides": []string{"libx.so.1"},
+		},
+		"c": {
+			"maintainer":  "C <c@example.org>",
+			"homepage":    "c.org",
+			"build-date":  "2999-01-01 12:00 UTC",
+			"run_depends": []string{"missing", "a>="},
+		},
+		// A homepage that doesn't parse is reported, not an error reading the repository.
+		"d": {
+			"maintainer": "D <d@example.org>",
+			"license":    "MIT",
+			"homepage":   "http://[::1",
+			"build-date": "2019-01-01 12:00 UTC",
+		},
+	})
+
+	cases := []struct {
+		Check    string
+		Severity Severity
+		Packages []string
+	}{
+		{"maintainer", SeverityWarning, []string{"b"}},
+		{"license", SeverityError, []string{"c"}},
+		{"homepage", SeverityWarning, []string{"c", "d"}},
+		{"homepage-http", SeverityInfo, []string{"b"}},
+		{"build-date", SeverityError, []string{"c"}},
+		{"run-depends", SeverityError, []string{"c", "c"}},
+		{"run-cycles", SeverityWarning, []string{"a"}},
+		{"shlib-providers", SeverityWarning, []string{"a"}},
+	}
+	for _, c := range cases {
+		checks, err := LintChecks(c.Check)
+		if err != nil {
+			t.Fatalf("LintChecks(%q) error = %v", c.Check, err)
+		}
+		var got []string
+		for _, issue := range Lint(rd, checks...).Issues {
+			if issue.Check != c.Check || issue.Severity != c.Severity {
+				t.Errorf("%s: issue %v has check %q, severity %v; want %q, %v",
+					c.Check, issue, issue.Check, issue.Severity, c.Check, c.Severity)
+			}
+			got = append(got, issue.Package)
+		}
+		if !reflect.DeepEqual(got, c.Packages) {
+			t.Errorf("%s: issues for packages %q; want %q", c.Check, got, c.Packages)
+		}
+	}
+}
+
+func TestLintReport(t *testing.T) {
+	rd := testRepoData(t, "current", testPackages{
+		"a": {"homepage": "http://a.org", "license": "MIT", "maintainer": "A <a@example.org>"},
+		"b": {"homepage": "http://b.org"},
+	})
+	report := Lint(rd)
+
+	var got []string
+	for _, issue := range report.Issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		"error license b: no license",
+		"warning maintainer b: no maintainer",
+		"info homepage-http a: homepage uses http: http://a.org",
+		"info homepage-http b: homepage uses http: http://b.org",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %q; want %q", got, want)
+	}
+
+	if n := report.Count(SeverityInfo); n != 2 {
+		t.Errorf("Count(info) = %d; want 2", n)
+	}
+	if n := len(report.Filter(SeverityWarning).Issues); n != 2 {
+		t.Errorf("Filter(warning) has %d issues; want 2", n)
+	}
+	if _, err := LintChecks("maintainer", "nope"); err == nil {
+		t.Error("LintChecks with unknown check: expected error")
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	cases := []struct {
+		In   string
+		Want Severity
+		Err  bool
+	}{
+		{"info", SeverityInfo, false},
+		{"Warning", SeverityWarning, false},
+		{"ERROR", SeverityError, false},
+		{"fatal", 0, true},
+	}
+	for _, c := range cases {
+		got, err := ParseSeverity(c.In)
+		if (err != nil) != c.Err || got != c.Want {
+			t.Errorf("ParseSeverity(%q) = %v, %v; want %v, error %t", c.In, got, err, c.Want, c.Err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package xrepo
+
+import (
+	"sort"
+
+	"go.spiff.io/nxtools/xbps"
+)
+
+// ParsedMaintainer returns the package's maintainer parsed into a name and email address.
+func (p *Package) ParsedMaintainer() xbps.Maintainer {
+	return xbps.ParseMaintainer(p.Maintainer)
+}
+
+// Orphaned returns a new Packages slice containing only orphaned packages.
+func (ps Packages) Orphaned() Packages {
+	return ps.Filter(func(p *Package) bool {
+		return p.ParsedMaintainer().Orphaned()
+	})
+}
+
+// ByMaintainer groups packages by their maintainer's key (see xbps.Maintainer.Key). Packages in
+// each group keep the order of the receiver.
+func (ps Packages) ByMaintainer() map[string]Packages {
+	groups := map[string]Packages{}
+	for _, p := range ps {
+		key := p.ParsedMaintainer().Key()
+		groups[key] = append(groups[key], p)
+	}
+	return groups
+}
+
+// MaintainerStats is a summary of the packages belonging to a single maintainer.
+type MaintainerStats struct {
+	Maintainer    xbps.Maintainer `json:"maintainer"`
+	Orphaned      bool            `json:"orphaned,omitempty"`
+	Packages      int             `json:"packages"`
+	InstalledSize int64           `json:"installed_size"`
+	// OldestBuild is the build date of OldestPackage, the least recently built package.
+	OldestBuild   Time     `json:"oldest_build"`
+	OldestPackage string   `json:"oldest_package"`
+	Names         []string `json:"names"`
+}
+
+// MaintainerStats returns a summary of each maintainer's packages, sorted by number of packages
+// (most first) and then by maintainer key. If a maintainer's name differs between packages, the
+// name used by the most recently built package is reported.
+func (ps Packages) MaintainerStats() []*MaintainerStats {
+	groups := ps.ByMaintainer()
+	stats := make([]*MaintainerStats, 0, len(groups))
+	for _, group := range groups {
+		s := &MaintainerStats{Packages: len(group)}
+		var newest Time
+		for _, p := range group {
+			built := p.BuildDate.Time()
+			if s.OldestPackage == "" || built.Before(s.OldestBuild.Time()) {
+				s.OldestBuild, s.OldestPackage = p.BuildDate, p.Name
+			}
+			if s.Maintainer == (xbps.Maintainer{}) || built.After(newest.Time()) {
+				s.Maintainer, newest = p.ParsedMaintainer(), p.BuildDate
+			}
+			s.InstalledSize += p.InstalledSize
+			s.Names = append(s.Names, p.Name)
+		}
+		s.Orphaned = s.Maintainer.Orphaned()
+		sort.Strings(s.Names)
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Packages != b.Packages {
+			return a.Packages > b.Packages
+		}
+		return a.Maintainer.Key() < b.Maintainer.Key()
+	})
+	return stats
+}
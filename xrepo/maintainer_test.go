@@ -0,0 +1,106 @@
+package xrepo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.spiff.io/nxtools/xbps"
+)
+
+func testMaintainerRepoData(t *testing.T) *RepoData {
+	return testRepoData(t, "current", testPackages{
+		"a": {"maintainer": "Alice <alice@example.org>", "installed_size": int64(100), "build-date": "2019-03-01 12:00 UTC"},
+		"b": {"maintainer": "A. Smith <ALICE@example.org>", "installed_size": int64(20), "build-date": "2019-01-01 12:00 UTC"},
+		"c": {"maintainer": "Alice <alice@example.org>", "installed_size": int64(3), "build-date": "2019-02-01 12:00 UTC"},
+		"d": {"maintainer": "Orphaned <orphan@voidlinux.org>", "installed_size": int64(4), "build-date": "2019-01-01 12:00 UTC"},
+		"e": {"maintainer": "orphan@voidlinux.org", "installed_size": int64(5), "build-date": "2018-01-01 12:00 UTC"},
+		"f": {"installed_size": int64(6), "build-date": "2019-01-01 12:00 UTC"},
+		"g": {"maintainer": "Bob", "build-date": "2019-01-01 12:00 UTC"},
+	})
+}
+
+func TestOrphaned(t *testing.T) {
+	var got []string
+	for _, p := range testMaintainerRepoData(t).Index().Orphaned() {
+		got = append(got, p.Name)
+	}
+	if want := []string{"d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Orphaned() = %q; want %q", got, want)
+	}
+}
+
+func TestByMaintainer(t *testing.T) {
+	groups := testMaintainerRepoData(t).Index().ByMaintainer()
+	got := map[string][]string{}
+	for key, ps := range groups {
+		for _, p := range ps {
+			got[key] = append(got[key], p.Name)
+		}
+	}
+	want := map[string][]string{
+		"alice@example.org":    {"a", "b", "c"},
+		"orphan@voidlinux.org": {"d", "e"},
+		"":                     {"f"},
+		"Bob":                  {"g"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ByMaintainer() = %q; want %q", got, want)
+	}
+}
+
+func TestMaintainerStats(t *testing.T) {
+	var got []MaintainerStats
+	for _, s := range testMaintainerRepoData(t).Index().MaintainerStats() {
+		got = append(got, *s)
+	}
+	date := func(s string) Time {
+		var tm Time
+		if err := tm.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	want := []MaintainerStats{
+		{
+			// The name is taken from the most recently built package.
+			Maintainer:    xbps.Maintainer{Name: "Alice", Email: "alice@example.org"},
+			Packages:      3,
+			InstalledSize: 123,
+			OldestBuild:   date("2019-01-01 12:00 UTC"),
+			OldestPackage: "b",
+			Names:         []string{"a", "b", "c"},
+		},
+		{
+			Maintainer:    xbps.Maintainer{Name: "Orphaned", Email: "orphan@voidlinux.org"},
+			Orphaned:      true,
+			Packages:      2,
+			InstalledSize: 9,
+			OldestBuild:   date("2018-01-01 12:00 UTC"),
+			OldestPackage: "e",
+			Names:         []string{"d", "e"},
+		},
+		// Packages without a maintainer are grouped under an empty key, which sorts first.
+		{
+			Packages:      1,
+			InstalledSize: 6,
+			OldestBuild:   date("2019-01-01 12:00 UTC"),
+			OldestPackage: "f",
+			Names:         []string{"f"},
+		},
+		{
+			Maintainer:    xbps.Maintainer{Name: "Bob"},
+			Packages:      1,
+			OldestBuild:   date("2019-01-01 12:00 UTC"),
+			OldestPackage: "g",
+			Names:         []string{"g"},
+		},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("MaintainerStats() = %+v; want %+v", got, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("MaintainerStats()[%d] = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -22,7 +22,7 @@ type Package struct {
 	BuildOptions   string `plist:"build-options" json:"build_options,omitempty"`
 	FilenameSHA256 string `plist:"filename-sha256" json:"filename_sha256,omitempty"`
 	FilenameSize   int64  `plist:"filename-size" json:"filename_size,omitempty"`
-	Homepage       *URL   `plist:"homepage" json:"homepage,omitempty"`
+	Homepage       string `plist:"homepage" json:"homepage,omitempty"`
 	InstalledSize  int64  `plist:"installed_size" json:"installed_size,omitempty"`
 	License        string `plist:"license" json:"license,omitempty"`
 	Maintainer     string `plist:"maintainer" json:"maintainer,omitempty"`
@@ -39,6 +39,7 @@ type Package struct {
 	Conflicts []string `plist:"conflicts" json:"conflicts,omitempty"`
 	Reverts   []string `plist:"reverts" json:"reverts,omitempty"`
 
+	Provides     []string            `plist:"provides" json:"provides,omitempty"`
 	Replaces     []string            `plist:"replaces" json:"replaces,omitempty"`
 	Alternatives map[string][]string `plist:"alternatives" json:"alternatives,omitempty"`
 
@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"os"
 	"sort"
+	"strings"
 
 	"go.spiff.io/nxtools/xbps"
 	"howett.net/plist"
@@ -37,6 +38,8 @@ type RepoData struct {
 	root      packageMap
 	index     Packages
 	nameIndex []string
+	virtual   map[string]Packages
+	shlibs    map[string]Packages
 	etag      string
 }
 
@@ -62,6 +65,22 @@ func (rd *RepoData) LoadRepo(path, repo string) error {
 	return rd.ReadRepo(fi, repo)
 }
 
+// LoadRepos loads all repodata files given as [repo=]path specs into a single RepoData. If a
+// spec has no repo, its packages are assigned the default repository, "current".
+func LoadRepos(specs ...string) (*RepoData, error) {
+	rd := NewRepoData()
+	for _, spec := range specs {
+		repo, path := "", spec
+		if i := strings.IndexByte(spec, '='); i != -1 {
+			repo, path = spec[:i], spec[i+1:]
+		}
+		if err := rd.LoadRepo(path, repo); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return rd, nil
+}
+
 // Index returns the complete set of packages held in the RepoData.
 // Callers must not modify the returned slice or packages.
 func (rd *RepoData) Index() Packages {
@@ -176,6 +195,8 @@ func (rd *RepoData) ReadRepoIndex(r io.Reader, repo string) error {
 	}
 	rd.nameIndex = names
 
+	rd.indexProviders()
+
 	etag, err := rd.computeETag()
 	if err != nil {
 		return err
@@ -0,0 +1,61 @@
+package xrepo
+
+import (
+	"bytes"
+	"testing"
+
+	"howett.net/plist"
+)
+
+// testPackages maps package names to their repodata fields (pkgver is filled in from the name if
+// unset).
+type testPackages map[string]map[string]interface{}
+
+// testRepoData returns repodata holding the given packages in repo.
+func testRepoData(t *testing.T, repo string, pkgs testPackages) *RepoData {
+	t.Helper()
+	rd := NewRepoData()
+	addTestPackages(t, rd, repo, pkgs)
+	return rd
+}
+
+// addTestPackages adds the given packages to rd in repo.
+func addTestPackages(t *testing.T, rd *RepoData, repo string, pkgs testPackages) {
+	t.Helper()
+	for name, fields := range pkgs {
+		if _, ok := fields["pkgver"]; !ok {
+			fields["pkgver"] = name + "-1.0_1"
+		}
+	}
+	p, err := plist.Marshal(pkgs, plist.XMLFormat)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if err := rd.ReadRepoIndex(bytes.NewReader(p), repo); err != nil {
+		t.Fatalf("ReadRepoIndex() error = %v", err)
+	}
+}
+
+func TestURLUnmarshalText(t *testing.T) {
+	cases := []struct {
+		In   string
+		Want string
+		Err  bool
+	}{
+		{"https://voidlinux.org", "https://voidlinux.org", false},
+		{"voidlinux.org/packages", "voidlinux.org/packages", false},
+		{"http://[::1", "", true},
+		{"http://a b.org", "", true},
+	}
+	for _, c := range cases {
+		var u URL
+		err := u.UnmarshalText([]byte(c.In))
+		if (err != nil) != c.Err {
+			t.Errorf("UnmarshalText(%q) error = %v; want error %t", c.In, err, c.Err)
+			continue
+		}
+		if err == nil && u.URL().String() != c.Want {
+			t.Errorf("UnmarshalText(%q) = %q; want %q", c.In, u.URL().String(), c.Want)
+		}
+	}
+}
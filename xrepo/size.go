@@ -0,0 +1,168 @@
+package xrepo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Size is a size in bytes. Its String method formats it using binary units.
+type Size int64
+
+var sizeUnits = [...]string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// String returns the size in human-readable binary units (e.g., "512 B" or "1.5 MiB").
+func (s Size) String() string {
+	if s < 1024 && s > -1024 {
+		return fmt.Sprintf("%d B", int64(s))
+	}
+	f := float64(s) / 1024
+	unit := 0
+	for ; (f >= 1024 || f <= -1024) && unit < len(sizeUnits)-1; unit++ {
+		f /= 1024
+	}
+	return fmt.Sprintf("%.1f %s", f, sizeUnits[unit])
+}
+
+// PackageSize is the installed and download size of a single package.
+type PackageSize struct {
+	Package    string `json:"package"`
+	Repository string `json:"repository"`
+	Installed  Size   `json:"installed_size"`
+	Download   Size   `json:"download_size"`
+	// Dependency is true if the package was only included as a dependency.
+	Dependency bool `json:"dependency,omitempty"`
+}
+
+// RepositorySize is the total installed and download size of the packages from a single
+// repository.
+type RepositorySize struct {
+	Repository string `json:"repository"`
+	Packages   int    `json:"packages"`
+	Installed  Size   `json:"installed_size"`
+	Download   Size   `json:"download_size"`
+}
+
+// SizeReport describes the size of a set of packages.
+type SizeReport struct {
+	// Packages is the size of each package, sorted by package name.
+	Packages []PackageSize `json:"packages"`
+	// Repositories is the total size of packages from each repository, sorted by repository.
+	Repositories []RepositorySize `json:"repositories"`
+	Installed    Size             `json:"installed_size"`
+	Download     Size             `json:"download_size"`
+}
+
+// InstallSize returns the size of the given packages and their run dependency closure. Installed
+// size is taken from installed_size and download size from filename-size. An error is returned if
+// any package does not exist.
+func (rd *RepoData) InstallSize(pkgs ...string) (*SizeReport, error) {
+	return rd.MarginalSize(pkgs, nil)
+}
+
+// MarginalSize returns the size of the given packages and their run dependency closure, excluding
+// packages in the closure of an already-installed base set. That is, it reports how much
+// installing pkgs adds to a system with base installed. Only one provider of each virtual
+// dependency is counted (see installClosure). An error is returned if any package in pkgs or base
+// does not exist.
+func (rd *RepoData) MarginalSize(pkgs, base []string) (*SizeReport, error) {
+	for _, names := range [][]string{pkgs, base} {
+		for _, name := range names {
+			if rd.Package(name) == nil {
+				return nil, fmt.Errorf("no such package: %s", name)
+			}
+		}
+	}
+
+	installed := rd.installClosure(base, nil)
+	closure := rd.installClosure(pkgs, installed)
+	names := make([]string, 0, len(closure))
+	for name := range closure {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	roots := map[string]bool{}
+	for _, name := range pkgs {
+		roots[name] = true
+	}
+
+	report := &SizeReport{
+		Packages:     []PackageSize{},
+		Repositories: []RepositorySize{},
+	}
+	repos := map[string]*RepositorySize{}
+	for _, name := range names {
+		p := rd.Package(name)
+		if installed[name] {
+			continue
+		}
+
+		ps := PackageSize{
+			Package:    name,
+			Repository: p.Repository,
+			Installed:  Size(p.InstalledSize),
+			Download:   Size(p.FilenameSize),
+			Dependency: !roots[name],
+		}
+		report.Packages = append(report.Packages, ps)
+		report.Installed += ps.Installed
+		report.Download += ps.Download
+
+		rs := repos[p.Repository]
+		if rs == nil {
+			rs = &RepositorySize{Repository: p.Repository}
+			repos[p.Repository] = rs
+		}
+		rs.Packages++
+		rs.Installed += ps.Installed
+		rs.Download += ps.Download
+	}
+
+	for _, rs := range repos {
+		report.Repositories = append(report.Repositories, *rs)
+	}
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	})
+
+	return report, nil
+}
+
+// installClosure returns the names of pkgs and the packages they depend on at run time,
+// recursively. Installing a virtual dependency installs only one of its providers, so only one is
+// included: a provider in have or already in the closure, if there is one, or otherwise the first
+// provider by name. Dependencies that cannot be resolved are ignored.
+func (rd *RepoData) installClosure(pkgs []string, have map[string]bool) map[string]bool {
+	closure := map[string]bool{}
+	queue := append([]string(nil), pkgs...)
+	sort.Strings(queue)
+	for _, name := range queue {
+		closure[name] = true
+	}
+
+	for len(queue) > 0 {
+		p := rd.Package(queue[0])
+		queue = queue[1:]
+		if p == nil {
+			continue
+		}
+		for _, dep := range p.RunDepends {
+			providers, _ := rd.Resolve(dep)
+			if len(providers) == 0 {
+				continue
+			}
+			pick := providers[0]
+			for _, pp := range providers {
+				if have[pp.Name] || closure[pp.Name] {
+					pick = pp
+					break
+				}
+			}
+			if !closure[pick.Name] {
+				closure[pick.Name] = true
+				queue = append(queue, pick.Name)
+			}
+		}
+	}
+	return closure
+}
@@ -0,0 +1,128 @@
+package xrepo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarginalSize(t *testing.T) {
+	rd := testRepoData(t, "current", testPackages{
+		"base":  {"installed_size": 100, "filename-size": 10, "run_depends": []string{"libc"}},
+		"libc":  {"installed_size": 1000, "filename-size": 100},
+		"app":   {"installed_size": 2048, "filename-size": 200, "run_depends": []string{"libc", "libui>=1.0_1"}},
+		"libui": {"installed_size": 4096, "filename-size": 400, "run_depends": []string{"libc"}},
+	})
+	addTestPackages(t, rd, "nonfree", testPackages{
+		"blob": {"installed_size": 1, "filename-size": 1, "run_depends": []string{"app"}},
+	})
+
+	cases := []struct {
+		Pkgs, Base []string
+		Want       *SizeReport
+	}{
+		{
+			[]string{"app"}, nil,
+			&SizeReport{
+				Packages: []PackageSize{
+					{"app", "current", 2048, 200, false},
+					{"libc", "current", 1000, 100, true},
+					{"libui", "current", 4096, 400, true},
+				},
+				Repositories: []RepositorySize{{"current", 3, 7144, 700}},
+				Installed:    7144,
+				Download:     700,
+			},
+		},
+		{
+			[]string{"blob"}, []string{"base"},
+			&SizeReport{
+				Packages: []PackageSize{
+					{"app", "current", 2048, 200, true},
+					{"blob", "nonfree", 1, 1, false},
+					{"libui", "current", 4096, 400, true},
+				},
+				Repositories: []RepositorySize{
+					{"current", 2, 6144, 600},
+					{"nonfree", 1, 1, 1},
+				},
+				Installed: 6145,
+				Download:  601,
+			},
+		},
+		{
+			[]string{"libc"}, []string{"app"},
+			&SizeReport{Packages: []PackageSize{}, Repositories: []RepositorySize{}},
+		},
+	}
+	for _, c := range cases {
+		got, err := rd.MarginalSize(c.Pkgs, c.Base)
+		if err != nil {
+			t.Errorf("MarginalSize(%q, %q) error = %v", c.Pkgs, c.Base, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("MarginalSize(%q, %q) = %+v; want %+v", c.Pkgs, c.Base, got, c.Want)
+		}
+	}
+
+	if _, err := rd.MarginalSize([]string{"app"}, []string{"nope"}); err == nil {
+		t.Error("MarginalSize with nonexistent base package: expected error")
+	}
+}
+
+func TestMarginalSizeVirtual(t *testing.T) {
+	rd := testRepoData(t, "current", testPackages{
+		"app":    {"installed_size": 1, "filename-size": 1, "run_depends": []string{"cron-daemon>=0"}},
+		"cronie": {"installed_size": 10, "filename-size": 10, "provides": []string{"cron-daemon-0_1"}},
+		"dcron":  {"installed_size": 100, "filename-size": 100, "provides": []string{"cron-daemon-0_1"}},
+		"system": {"installed_size": 1000, "filename-size": 1000, "run_depends": []string{"dcron"}},
+		"both":   {"installed_size": 1, "filename-size": 1, "run_depends": []string{"app", "dcron"}},
+	})
+
+	cases := []struct {
+		Pkgs, Base []string
+		Want       []string
+		Installed  Size
+	}{
+		// Only the first provider is counted.
+		{[]string{"app"}, nil, []string{"app", "cronie"}, 11},
+		// A provider that is already installed is used instead.
+		{[]string{"app"}, []string{"system"}, []string{"app"}, 1},
+		// As is one installed along with the package.
+		{[]string{"both"}, nil, []string{"app", "both", "dcron"}, 102},
+	}
+	for _, c := range cases {
+		got, err := rd.MarginalSize(c.Pkgs, c.Base)
+		if err != nil {
+			t.Errorf("MarginalSize(%q, %q) error = %v", c.Pkgs, c.Base, err)
+			continue
+		}
+		var names []string
+		for _, ps := range got.Packages {
+			names = append(names, ps.Package)
+		}
+		if !reflect.DeepEqual(names, c.Want) || got.Installed != c.Installed {
+			t.Errorf("MarginalSize(%q, %q) = %q, %d installed; want %q, %d",
+				c.Pkgs, c.Base, names, got.Installed, c.Want, c.Installed)
+		}
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	cases := []struct {
+		In   Size
+		Want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{-2048, "-2.0 KiB"},
+	}
+	for _, c := range cases {
+		if got := c.In.String(); got != c.Want {
+			t.Errorf("Size(%d).String() = %q; want %q", int64(c.In), got, c.Want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package xrepo
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceRevision returns the source package name and commit recorded in the package's
+// source-revisions field (e.g., "gtk+3:1a2b3c4"). If the field has no commit, commit is empty. If
+// the field is empty, source is the package's name.
+func (p *Package) SourceRevision() (source, commit string) {
+	rev := strings.TrimSpace(p.SourceRevisions)
+	if rev == "" {
+		return p.Name, ""
+	}
+	if i := strings.LastIndexByte(rev, ':'); i != -1 {
+		return rev[:i], rev[i+1:]
+	}
+	return rev, ""
+}
+
+// BuiltBefore returns a new Packages slice containing only packages built before t.
+func (ps Packages) BuiltBefore(t time.Time) Packages {
+	return ps.Filter(func(p *Package) bool {
+		return p.BuildDate.Time().Before(t)
+	})
+}
+
+// SourceGroup is a set of packages built from the same source package (template).
+type SourceGroup struct {
+	Source   string   `json:"source"`
+	Commits  []string `json:"commits"`
+	Packages Packages `json:"-"`
+	Names    []string `json:"packages"`
+}
+
+// BySource groups packages by the source package named in their source-revisions, so that
+// subpackages are grouped with their main package. Groups are sorted by source name, and each
+// group lists the distinct commits its packages were built from.
+func (ps Packages) BySource() []*SourceGroup {
+	bySource := map[string]*SourceGroup{}
+	for _, p := range ps {
+		source, commit := p.SourceRevision()
+		g := bySource[source]
+		if g == nil {
+			g = &SourceGroup{Source: source}
+			bySource[source] = g
+		}
+		g.Packages = append(g.Packages, p)
+		g.Names = append(g.Names, p.Name)
+		if commit != "" && !containsString(g.Commits, commit) {
+			g.Commits = append(g.Commits, commit)
+		}
+	}
+
+	groups := make([]*SourceGroup, 0, len(bySource))
+	for _, g := range bySource {
+		sort.Strings(g.Commits)
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Source < groups[j].Source })
+	return groups
+}
+
+// Drift describes a package whose build differs from the other packages built from the same
+// source package, which usually means that only some of them were rebuilt.
+type Drift struct {
+	Source  string `json:"source"`
+	Package string `json:"package"`
+	// Field is the field that differs: "version", "revision", "commit", or "build-date".
+	Field string `json:"field"`
+	Value string `json:"value"`
+	// Want is the value of the field held by most of the package's siblings or, for build-date,
+	// the build date of the most recently built sibling.
+	Want string `json:"want"`
+}
+
+// Drift returns packages whose version, revision, or source commit differ from those of most of
+// their siblings (see BySource), and packages built more than tolerance before their most recently
+// built sibling. Results are sorted by source, package, and field.
+func (ps Packages) Drift(tolerance time.Duration) []Drift {
+	var drift []Drift
+	for _, g := range ps.BySource() {
+		if len(g.Packages) < 2 {
+			continue
+		}
+
+		fields := []struct {
+			name  string
+			value func(*Package) string
+		}{
+			{"version", func(p *Package) string { return p.Version }},
+			{"revision", func(p *Package) string { return strconv.Itoa(p.Revision) }},
+			{"commit", func(p *Package) string { _, c := p.SourceRevision(); return c }},
+		}
+		for _, f := range fields {
+			want := majority(g.Packages, f.value)
+			for _, p := range g.Packages {
+				if v := f.value(p); v != want {
+					drift = append(drift, Drift{g.Source, p.Name, f.name, v, want})
+				}
+			}
+		}
+
+		newest := g.Packages[0].BuildDate.Time()
+		for _, p := range g.Packages[1:] {
+			if t := p.BuildDate.Time(); t.After(newest) {
+				newest = t
+			}
+		}
+		for _, p := range g.Packages {
+			if t := p.BuildDate.Time(); newest.Sub(t) > tolerance {
+				drift = append(drift, Drift{
+					g.Source, p.Name, "build-date",
+					t.Format(time.RFC3339), newest.Format(time.RFC3339),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(drift, func(i, j int) bool {
+		a, b := drift[i], drift[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		} else if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Field < b.Field
+	})
+	return drift
+}
+
+// majority returns the most common value of fn over ps. Ties are broken by the greatest value.
+func majority(ps Packages, fn func(*Package) string) string {
+	counts := map[string]int{}
+	for _, p := range ps {
+		counts[fn(p)]++
+	}
+	var (
+		best  string
+		count int
+	)
+	for v, n := range counts {
+		if n > count || n == count && v > best {
+			best, count = v, n
+		}
+	}
+	return best
+}
+
+// containsString returns whether ss contains s.
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
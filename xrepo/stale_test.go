@@ -0,0 +1,84 @@
+package xrepo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDrift(t *testing.T) {
+	rd := testRepoData(t, "current", testPackages{
+		"foo": {
+			"pkgver":           "foo-1.2_1",
+			"source-revisions": "foo:abc",
+			"build-date":       "2020-01-10 12:00 UTC",
+		},
+		"foo-devel": {
+			"pkgver":           "foo-devel-1.2_1",
+			"source-revisions": "foo:abc",
+			"build-date":       "2020-01-10 11:00 UTC",
+		},
+		"libfoo": {
+			"pkgver":           "libfoo-1.1_2",
+			"source-revisions": "foo:def",
+			"build-date":       "2020-01-01 12:00 UTC",
+		},
+		"bar": {
+			"pkgver":           "bar-3.0_1",
+			"source-revisions": "bar:123",
+			"build-date":       "2018-01-01 12:00 UTC",
+		},
+	})
+
+	cases := []struct {
+		Tolerance time.Duration
+		Want      []Drift
+	}{
+		{
+			24 * time.Hour,
+			[]Drift{
+				{"foo", "libfoo", "build-date", "2020-01-01T12:00:00Z", "2020-01-10T12:00:00Z"},
+				{"foo", "libfoo", "commit", "def", "abc"},
+				{"foo", "libfoo", "revision", "2", "1"},
+				{"foo", "libfoo", "version", "1.1", "1.2"},
+			},
+		},
+		{
+			30 * time.Minute,
+			[]Drift{
+				{"foo", "foo-devel", "build-date", "2020-01-10T11:00:00Z", "2020-01-10T12:00:00Z"},
+				{"foo", "libfoo", "build-date", "2020-01-01T12:00:00Z", "2020-01-10T12:00:00Z"},
+				{"foo", "libfoo", "commit", "def", "abc"},
+				{"foo", "libfoo", "revision", "2", "1"},
+				{"foo", "libfoo", "version", "1.1", "1.2"},
+			},
+		},
+	}
+	for _, c := range cases {
+		if got := rd.Index().Drift(c.Tolerance); !reflect.DeepEqual(got, c.Want) {
+			t.Errorf("Drift(%v) = %+v; want %+v", c.Tolerance, got, c.Want)
+		}
+	}
+}
+
+func TestBySource(t *testing.T) {
+	rd := testRepoData(t, "current", testPackages{
+		"foo":       {"source-revisions": "foo:abc"},
+		"foo-devel": {"source-revisions": "foo:def"},
+		"gtk+3":     {"source-revisions": "gtk+3:1a2b"},
+		"bar":       {},
+	})
+
+	var got []SourceGroup
+	for _, g := range rd.Index().BySource() {
+		got = append(got, SourceGroup{Source: g.Source, Commits: g.Commits, Names: g.Names})
+	}
+	want := []SourceGroup{
+		{Source: "bar", Names: []string{"bar"}},
+		{Source: "foo", Commits: []string{"abc", "def"}, Names: []string{"foo", "foo-devel"}},
+		{Source: "gtk+3", Commits: []string{"1a2b"}, Names: []string{"gtk+3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BySource() = %+v; want %+v", got, want)
+	}
+}